@@ -73,7 +73,7 @@ var doc = `{
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Currently supported question types: yesno",
+                "description": "Currently supported question types: yesno, text, choice (requires options).\nWhen async is set the response is returned immediately and the answer can be polled with GET /question/{id}.\nyesno questions with approvals or requireAllSinks resolve once enough distinct people answered \"yes\", or any of them answered \"no\". The individual votes are listed in answer.votes.\nWhen timeoutBehavior is \"error\" a timed out question is reported with status 504.\nWhen callbackUrl is set the final response is POSTed to it, signed with HMAC-SHA256 using questions.callback_secret in the X-Notifier-Signature header.",
                 "consumes": [
                     "application/json"
                 ],
@@ -105,6 +105,188 @@ var doc = `{
                         "schema": {
                             "$ref": "#/definitions/notifier.ErrorResponse"
                         }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/notifier.PostQuestionResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/question/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the status and answer (if any) of a question asked with POST /question by the same user",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Gets the status of a question",
+                "operationId": "get-question",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Question ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/notifier.PostQuestionResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/notifier.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/notifier.PostQuestionResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Cancels a pending question asked by the same user. The sinks stop waiting for an answer.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Cancels a question",
+                "operationId": "delete-question",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Question ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/notifier.PostQuestionResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/notifier.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/notifier.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webpush/subscriptions": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Registers a PushSubscription created with the VAPID public key of the webpush sink. The /webpush page does it from the browser.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Subscribe a browser to web push notifications",
+                "operationId": "post-webpush-subscription",
+                "parameters": [
+                    {
+                        "description": "PushSubscription to register",
+                        "name": "subscription",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/notifier.WebPushSubscriptionBody"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": ""
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/notifier.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/notifier.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Removes a subscription of the logged in user, the subscriptions of other users are left untouched.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Unsubscribe a browser from web push notifications",
+                "operationId": "delete-webpush-subscription",
+                "parameters": [
+                    {
+                        "description": "PushSubscription to remove, only the endpoint is used",
+                        "name": "subscription",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/notifier.WebPushSubscriptionBody"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": ""
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/notifier.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/notifier.ErrorResponse"
+                        }
                     }
                 }
             }
@@ -117,10 +299,40 @@ var doc = `{
                 "answerDuration": {
                     "type": "integer"
                 },
+                "answeredAt": {
+                    "type": "string"
+                },
+                "answeredBy": {
+                    "$ref": "#/definitions/notifier.Answerer"
+                },
                 "timedOut": {
                     "type": "boolean"
                 },
-                "value": {}
+                "value": {},
+                "votes": {
+                    "description": "Votes lists the individual answers of a question with multiple approvers.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/notifier.Answer"
+                    }
+                }
+            }
+        },
+        "notifier.Answerer": {
+            "type": "object",
+            "properties": {
+                "displayName": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "sink": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
             }
         },
         "notifier.ErrorResponse": {
@@ -134,9 +346,21 @@ var doc = `{
         "notifier.PostNotifyBody": {
             "type": "object",
             "properties": {
+                "action": {
+                    "description": "Action is \"trigger\" (default) or \"resolve\". Resolving an incident requires a dedupKey\nand is only delivered to the incident management sinks.",
+                    "type": "string"
+                },
                 "body": {
                     "type": "string"
                 },
+                "dedupKey": {
+                    "description": "DedupKey identifies the incident in incident management sinks (PagerDuty, Opsgenie).",
+                    "type": "string"
+                },
+                "severity": {
+                    "description": "Severity is one of \"emergency\", \"alert\", \"critical\", \"error\", \"warning\", \"notice\", \"info\" or \"debug\".\nSinks which support it use their configured default when it is empty.",
+                    "type": "string"
+                },
                 "title": {
                     "type": "string"
                 }
@@ -162,13 +386,43 @@ var doc = `{
         "notifier.PostQuestionBody": {
             "type": "object",
             "properties": {
+                "approvals": {
+                    "description": "Approvals is the number of distinct approvers needed for a yesno question. Any \"no\" rejects it.",
+                    "type": "integer"
+                },
+                "async": {
+                    "description": "Async makes the request return the question ID immediately instead of waiting for the answer.",
+                    "type": "boolean"
+                },
+                "callbackUrl": {
+                    "description": "CallbackURL receives the final PostQuestionResponse as a signed POST request.",
+                    "type": "string"
+                },
+                "defaultAnswer": {
+                    "description": "DefaultAnswer is the value of the answer when the question times out and timeoutBehavior is \"default\"."
+                },
                 "kind": {
                     "type": "string"
                 },
+                "options": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/notifier.QuestionOption"
+                    }
+                },
+                "requireAllSinks": {
+                    "description": "RequireAllSinks makes a yesno question require a \"yes\" from every sink.",
+                    "type": "boolean"
+                },
                 "text": {
                     "type": "string"
                 },
                 "timeout": {
+                    "description": "Timeout in nanoseconds, 0 means that the question never times out.",
+                    "type": "integer"
+                },
+                "timeoutBehavior": {
+                    "description": "TimeoutBehavior is one of \"default\", \"null\" or \"error\". Defaults to \"default\" when defaultAnswer\nis set, \"null\" otherwise (yesno questions default to a \"No\" answer).",
                     "type": "string"
                 }
             }
@@ -184,6 +438,42 @@ var doc = `{
                     "additionalProperties": {
                         "type": "string"
                     }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "notifier.QuestionOption": {
+            "type": "object",
+            "properties": {
+                "label": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "string"
+                }
+            }
+        },
+        "notifier.WebPushSubscriptionBody": {
+            "type": "object",
+            "properties": {
+                "endpoint": {
+                    "type": "string"
+                },
+                "keys": {
+                    "type": "object",
+                    "properties": {
+                        "auth": {
+                            "type": "string"
+                        },
+                        "p256dh": {
+                            "type": "string"
+                        }
+                    }
                 }
             }
         }
@@ -3,11 +3,18 @@ module github.com/alufers/notifier
 go 1.17
 
 require (
+	github.com/SherClockHolmes/webpush-go v1.1.3
 	github.com/arsmn/fiber-swagger/v2 v2.17.0
+	github.com/bwmarrin/discordgo v0.24.0
+	github.com/eclipse/paho.mqtt.golang v1.3.5
 	github.com/go-telegram-bot-api/telegram-bot-api v4.6.4+incompatible
+	github.com/godbus/dbus/v5 v5.0.6
 	github.com/gofiber/fiber/v2 v2.19.0
 	github.com/golang-jwt/jwt/v4 v4.1.0
+	github.com/mattn/go-xmpp v0.0.0-20211029151415-912ba614897a
 	github.com/spf13/viper v1.9.0
+	github.com/swaggo/swag v1.7.1
+	github.com/thoj/go-ircevent v0.0.0-20210723090443-73e444401d64
 )
 
 require (
@@ -20,6 +27,8 @@ require (
 	github.com/go-openapi/jsonreference v0.19.5 // indirect
 	github.com/go-openapi/spec v0.20.3 // indirect
 	github.com/go-openapi/swag v0.19.14 // indirect
+	github.com/golang-jwt/jwt v3.2.2+incompatible // indirect
+	github.com/gorilla/websocket v1.4.2 // indirect
 	github.com/hashicorp/hcl v1.0.0 // indirect
 	github.com/josharian/intern v1.0.0 // indirect
 	github.com/klauspost/compress v1.13.4 // indirect
@@ -33,12 +42,12 @@ require (
 	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/subosito/gotenv v1.2.0 // indirect
 	github.com/swaggo/files v0.0.0-20190704085106-630677cd5c14 // indirect
-	github.com/swaggo/swag v1.7.1 // indirect
 	github.com/technoweenie/multipartstreamer v1.0.1 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/valyala/fasthttp v1.29.0 // indirect
 	github.com/valyala/tcplisten v1.0.0 // indirect
-	golang.org/x/net v0.0.0-20210510120150-4163338589ed // indirect
+	golang.org/x/crypto v0.0.0-20210817164053-32db794688a5 // indirect
+	golang.org/x/net v0.0.0-20210614182718-04defd469f4e // indirect
 	golang.org/x/sys v0.0.0-20210823070655-63515b42dcdf // indirect
 	golang.org/x/text v0.3.6 // indirect
 	golang.org/x/tools v0.1.5 // indirect
@@ -0,0 +1,180 @@
+package notifier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/spf13/viper"
+)
+
+const answerLinkAudience = "answer"
+
+// answerLinkKey derives the key signing the answer links from the JWT secret,
+// so that a link token can never be accepted as a login token.
+func answerLinkKey() []byte {
+	mac := hmac.New(sha256.New, []byte(viper.GetString("http.jwt_secret")))
+	mac.Write([]byte("notifier answer links"))
+	return mac.Sum(nil)
+}
+
+// answerLinkClaims are the claims of the token embedded in an answer link.
+type answerLinkClaims struct {
+	jwt.RegisteredClaims
+	ListenerID string      `json:"lid"`
+	Value      interface{} `json:"val,omitempty"`
+	Answerer   *Answerer   `json:"ans,omitempty"`
+}
+
+type answerLinkListener struct {
+	question  *Question
+	askedTime time.Time
+	handler   func(answer *Answer)
+}
+
+// AnswerLinkManager issues signed, single-use links which answer a question
+// when opened, and dispatches the answers to the sinks waiting for them.
+type AnswerLinkManager struct {
+	mutex      sync.Mutex
+	listeners  map[string]*answerLinkListener
+	usedTokens map[string]time.Time
+}
+
+func NewAnswerLinkManager() *AnswerLinkManager {
+	return &AnswerLinkManager{
+		listeners:  make(map[string]*answerLinkListener),
+		usedTokens: make(map[string]time.Time),
+	}
+}
+
+// AddListener registers a handler called with the answer when one of the
+// links created for the returned listener ID is used. The returned function
+// removes the listener, which invalidates all of its links.
+func (m *AnswerLinkManager) AddListener(question *Question, handler func(answer *Answer)) (string, func()) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	listenerID := generateID()
+	m.listeners[listenerID] = &answerLinkListener{
+		question:  question,
+		askedTime: time.Now(),
+		handler:   handler,
+	}
+	return listenerID, func() {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		delete(m.listeners, listenerID)
+	}
+}
+
+// CreateLink returns a URL which answers the question with the given value.
+// A nil value creates a link to a form where the answer can be typed in.
+func (m *AnswerLinkManager) CreateLink(listenerID string, value interface{}, answerer *Answerer, expires time.Time) (string, error) {
+	publicURL := strings.TrimSuffix(viper.GetString("http.public_url"), "/")
+	if publicURL == "" {
+		return "", fmt.Errorf("http.public_url is not configured")
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &answerLinkClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        generateID(),
+			Audience:  jwt.ClaimStrings{answerLinkAudience},
+			ExpiresAt: jwt.NewNumericDate(expires),
+		},
+		ListenerID: listenerID,
+		Value:      value,
+		Answerer:   answerer,
+	})
+	tokenString, err := token.SignedString(answerLinkKey())
+	if err != nil {
+		return "", fmt.Errorf("failed to sign answer link: %w", err)
+	}
+	return fmt.Sprintf("%v/answer/%v", publicURL, tokenString), nil
+}
+
+func (m *AnswerLinkManager) parseToken(tokenString string) (*answerLinkClaims, error) {
+	claims := &answerLinkClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return answerLinkKey(), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid answer link: %w", err)
+	}
+	if !claims.VerifyAudience(answerLinkAudience, true) {
+		return nil, fmt.Errorf("invalid answer link: wrong audience")
+	}
+	return claims, nil
+}
+
+// Lookup returns the question a link answers and the value it answers with,
+// without using up the link.
+func (m *AnswerLinkManager) Lookup(tokenString string) (*Question, interface{}, error) {
+	claims, err := m.parseToken(tokenString)
+	if err != nil {
+		return nil, nil, err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, used := m.usedTokens[claims.ID]; used {
+		return nil, nil, fmt.Errorf("this link has already been used")
+	}
+	listener, ok := m.listeners[claims.ListenerID]
+	if !ok {
+		return nil, nil, fmt.Errorf("the question is no longer waiting for an answer")
+	}
+	return listener.question, claims.Value, nil
+}
+
+// Resolve answers the question using the link. The typed value is used for
+// links created without a value.
+func (m *AnswerLinkManager) Resolve(tokenString string, typedValue string) (*Answer, error) {
+	claims, err := m.parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	m.mutex.Lock()
+	if _, used := m.usedTokens[claims.ID]; used {
+		m.mutex.Unlock()
+		return nil, fmt.Errorf("this link has already been used")
+	}
+	listener, ok := m.listeners[claims.ListenerID]
+	if !ok {
+		m.mutex.Unlock()
+		return nil, fmt.Errorf("the question is no longer waiting for an answer")
+	}
+	value := claims.Value
+	if value == nil {
+		if typedValue == "" {
+			m.mutex.Unlock()
+			return nil, fmt.Errorf("the answer is empty")
+		}
+		value = typedValue
+	}
+	m.usedTokens[claims.ID] = claims.ExpiresAt.Time
+	m.forgetExpiredTokens()
+	m.mutex.Unlock()
+
+	answer := &Answer{
+		Value:          value,
+		AnwserDuration: time.Since(listener.askedTime),
+		AnsweredBy:     claims.Answerer,
+		AnsweredAt:     time.Now(),
+	}
+	listener.handler(answer)
+	return answer, nil
+}
+
+// forgetExpiredTokens drops used tokens which can no longer pass validation anyway.
+func (m *AnswerLinkManager) forgetExpiredTokens() {
+	now := time.Now()
+	for id, expires := range m.usedTokens {
+		if expires.Before(now) {
+			delete(m.usedTokens, id)
+		}
+	}
+}
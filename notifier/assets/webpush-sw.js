@@ -0,0 +1,25 @@
+// Service worker showing the notifications pushed by the webpush sink.
+
+self.addEventListener("push", (event) => {
+  const msg = event.data ? event.data.json() : { title: "Notification", body: "" };
+  event.waitUntil(
+    self.registration.showNotification(msg.title, {
+      body: msg.body,
+      tag: msg.tag || undefined,
+      actions: msg.actions || [],
+      requireInteraction: (msg.actions || []).length > 0,
+      data: { links: msg.links || {} },
+    })
+  );
+});
+
+self.addEventListener("notificationclick", (event) => {
+  event.notification.close();
+  const links = event.notification.data.links;
+  if (event.action && links[event.action]) {
+    // answer links only answer with a POST, GET shows a confirmation page
+    event.waitUntil(fetch(links[event.action], { method: "POST" }));
+    return;
+  }
+  event.waitUntil(self.clients.openWindow("/ui"));
+});
@@ -0,0 +1,274 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/user"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	desktopNotificationsInterface = "org.freedesktop.Notifications"
+	desktopNotificationsPath      = "/org/freedesktop/Notifications"
+)
+
+// DesktopNotificationSink shows notifications on the Linux desktop through
+// the org.freedesktop.Notifications service on the session D-Bus. Yes/no and
+// choice questions are shown with action buttons.
+type DesktopNotificationSink struct {
+	Address  string // D-Bus address, the session bus from DBUS_SESSION_BUS_ADDRESS by default
+	AppName  string
+	Icon     string        // icon name or file:// URI
+	Timeout  time.Duration // how long notifications are shown, the server decides when 0
+	conn     *dbus.Conn
+	markup   bool // the server interprets the body as markup
+	actions  bool // the server shows action buttons, needed for questions
+	username string
+
+	mutex     sync.Mutex
+	listeners map[uint32]func(signal *dbus.Signal)
+}
+
+func (sink *DesktopNotificationSink) Init() error {
+	if sink.AppName == "" {
+		sink.AppName = "notifier"
+	}
+	var err error
+	if sink.Address != "" {
+		sink.conn, err = dbus.Connect(sink.Address)
+	} else {
+		sink.conn, err = dbus.ConnectSessionBus()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to d-bus: %w", err)
+	}
+	var capabilities []string
+	if err := sink.notifications().Call(desktopNotificationsInterface+".GetCapabilities", 0).Store(&capabilities); err != nil {
+		sink.conn.Close()
+		return fmt.Errorf("no notification server is running: %w", err)
+	}
+	for _, capability := range capabilities {
+		switch capability {
+		case "body-markup":
+			sink.markup = true
+		case "actions":
+			sink.actions = true
+		}
+	}
+	if u, err := user.Current(); err == nil {
+		sink.username = u.Username
+	}
+
+	sink.listeners = make(map[uint32]func(signal *dbus.Signal))
+	err = sink.conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(desktopNotificationsPath),
+		dbus.WithMatchInterface(desktopNotificationsInterface),
+	)
+	if err != nil {
+		sink.conn.Close()
+		return fmt.Errorf("failed to subscribe to notification signals: %w", err)
+	}
+	signals := make(chan *dbus.Signal, 16)
+	sink.conn.Signal(signals)
+	go sink.dispatchSignals(signals)
+
+	log.Printf("Successfully initialized %T", sink)
+	return nil
+}
+
+func (sink *DesktopNotificationSink) notifications() dbus.BusObject {
+	return sink.conn.Object(desktopNotificationsInterface, desktopNotificationsPath)
+}
+
+func (sink *DesktopNotificationSink) DeliverNotification(notification *Notification) error {
+	summary := notification.Title
+	if summary == "" {
+		summary = sink.AppName
+	}
+	_, err := sink.notify(summary, notification.Body, nil, desktopUrgency(notification.Severity), sink.expireTimeout(), nil)
+	return err
+}
+
+// notify shows a notification and returns its ID. The listener is registered
+// before the signals of the notification can be dispatched.
+func (sink *DesktopNotificationSink) notify(summary string, body string, actions []string, urgency byte, expireTimeout int32, listener func(signal *dbus.Signal)) (uint32, error) {
+	if sink.markup {
+		body = desktopMarkupEscaper.Replace(body)
+	}
+	if actions == nil {
+		actions = []string{}
+	}
+	hints := map[string]dbus.Variant{
+		"urgency": dbus.MakeVariant(urgency),
+	}
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	var id uint32
+	err := sink.notifications().Call(desktopNotificationsInterface+".Notify", 0,
+		sink.AppName, uint32(0), sink.Icon, summary, body, actions, hints, expireTimeout,
+	).Store(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to show notification: %w", err)
+	}
+	if listener != nil {
+		sink.listeners[id] = listener
+	}
+	return id, nil
+}
+
+func (sink *DesktopNotificationSink) AskQuestion(ctx context.Context, question *Question) (*Answer, error) {
+	if !sink.actions {
+		return nil, fmt.Errorf("the notification server does not support actions")
+	}
+	actions := []string{}
+	values := map[string]interface{}{}
+	switch question.Kind {
+	case QuestionKind_YesNo:
+		actions = append(actions, "yes", "Yes", "no", "No")
+		values["yes"] = true
+		values["no"] = false
+	case QuestionKind_Choice:
+		for i, option := range question.Options {
+			key := fmt.Sprintf("option-%v", i)
+			actions = append(actions, key, option.Label)
+			values[key] = option.Value
+		}
+	default:
+		return nil, fmt.Errorf("unsupported question kind: %v", question.Kind)
+	}
+
+	questionAskedTime := time.Now()
+	// a nil answer means that the notification was dismissed
+	answerChan := make(chan *Answer, 1)
+	// questions stay on the screen until they are answered, dismissed or time out
+	id, err := sink.notify("Question", question.Text, actions, 2, 0, func(signal *dbus.Signal) {
+		if signal.Name == desktopNotificationsInterface+".NotificationClosed" {
+			// the signals are dispatched in order, so an action invoked before
+			// the notification was closed has already been sent
+			select {
+			case answerChan <- nil:
+			default:
+			}
+			return
+		}
+		if signal.Name != desktopNotificationsInterface+".ActionInvoked" || len(signal.Body) < 2 {
+			return
+		}
+		key, _ := signal.Body[1].(string)
+		value, ok := values[key]
+		if !ok {
+			return
+		}
+		select {
+		case answerChan <- &Answer{
+			Value:          value,
+			AnwserDuration: time.Since(questionAskedTime),
+			AnsweredBy: &Answerer{
+				Sink:     "desktop",
+				ID:       sink.username,
+				Username: sink.username,
+			},
+			AnsweredAt: time.Now(),
+		}:
+		default:
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to send question: %w", err)
+	}
+	defer sink.removeListener(id)
+
+	select {
+	case answer := <-answerChan:
+		if answer == nil {
+			// the other sinks can still answer the question
+			return nil, fmt.Errorf("the question was dismissed without an answer")
+		}
+		sink.close(id)
+		return answer, nil
+	case <-ctx.Done():
+		sink.close(id)
+		if _, err := sink.notify(question.TimeoutLabel(), question.Text, nil, 1, sink.expireTimeout(), nil); err != nil {
+			log.Printf("failed to send desktop notification: %v", err)
+		}
+		return &Answer{
+			Value:          question.TimeoutValue(),
+			TimedOut:       true,
+			AnwserDuration: time.Since(questionAskedTime),
+		}, nil
+	}
+}
+
+// expireTimeout returns the expire_timeout argument of Notify in milliseconds, -1 lets the server decide.
+func (sink *DesktopNotificationSink) expireTimeout() int32 {
+	if sink.Timeout > 0 {
+		return int32(sink.Timeout.Milliseconds())
+	}
+	return -1
+}
+
+func (sink *DesktopNotificationSink) close(id uint32) {
+	if err := sink.notifications().Call(desktopNotificationsInterface+".CloseNotification", 0, id).Err; err != nil {
+		log.Printf("failed to close desktop notification: %v", err)
+	}
+}
+
+func (sink *DesktopNotificationSink) removeListener(id uint32) {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	delete(sink.listeners, id)
+}
+
+// dispatchSignals passes the signals of the notification server to the
+// listener of the notification they are about.
+func (sink *DesktopNotificationSink) dispatchSignals(signals chan *dbus.Signal) {
+	for signal := range signals {
+		if len(signal.Body) == 0 {
+			continue
+		}
+		id, ok := signal.Body[0].(uint32)
+		if !ok {
+			continue
+		}
+		sink.mutex.Lock()
+		listener := sink.listeners[id]
+		sink.mutex.Unlock()
+		if listener != nil {
+			listener(signal)
+		}
+	}
+	log.Printf("D-Bus connection of %T closed", sink)
+}
+
+var desktopMarkupEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// desktopUrgency maps the severity to the urgency hint: 0 (low), 1 (normal) or 2 (critical).
+func desktopUrgency(severity Severity) byte {
+	switch severity {
+	case Severity_Emergency, Severity_Alert, Severity_Critical:
+		return 2
+	case Severity_Info, Severity_Debug:
+		return 0
+	}
+	return 1
+}
+
+func init() {
+	registerSinkType("desktop", func(config sinkConfig, managers *sinkManagers) (NotificationSink, error) {
+		timeout, err := config.Duration("timeout")
+		if err != nil {
+			return nil, err
+		}
+		return &DesktopNotificationSink{
+			Address: config.String("address"),
+			AppName: config.String("app_name"),
+			Icon:    config.String("icon"),
+			Timeout: timeout,
+		}, nil
+	})
+}
@@ -0,0 +1,246 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const testDBusConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:path=%v</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// startTestDBus starts a private dbus-daemon and returns its address.
+func startTestDBus(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "session.conf")
+	config := fmt.Sprintf(testDBusConfig, filepath.Join(dir, "bus"))
+	if err := os.WriteFile(configPath, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("dbus-daemon", "--config-file="+configPath, "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("failed to read the address of dbus-daemon: %v", err)
+	}
+	return strings.TrimSpace(address)
+}
+
+type testNotification struct {
+	ID      uint32
+	Summary string
+	Body    string
+	Actions []string
+	Urgency byte
+}
+
+// testNotificationServer implements the methods of org.freedesktop.Notifications
+// used by DesktopNotificationSink.
+type testNotificationServer struct {
+	conn          *dbus.Conn
+	capabilities  []string
+	notifications chan *testNotification
+
+	mutex  sync.Mutex
+	nextID uint32
+}
+
+func startTestNotificationServer(t *testing.T, address string, capabilities []string) *testNotificationServer {
+	t.Helper()
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	server := &testNotificationServer{
+		conn:          conn,
+		capabilities:  capabilities,
+		notifications: make(chan *testNotification, 16),
+	}
+	if err := conn.Export(server, desktopNotificationsPath, desktopNotificationsInterface); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := conn.RequestName(desktopNotificationsInterface, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("failed to own %v: %v", desktopNotificationsInterface, err)
+	}
+	return server
+}
+
+func (server *testNotificationServer) GetCapabilities() ([]string, *dbus.Error) {
+	return server.capabilities, nil
+}
+
+func (server *testNotificationServer) Notify(appName string, replacesID uint32, icon string, summary string, body string, actions []string, hints map[string]dbus.Variant, expireTimeout int32) (uint32, *dbus.Error) {
+	server.mutex.Lock()
+	server.nextID++
+	id := server.nextID
+	server.mutex.Unlock()
+	urgency, _ := hints["urgency"].Value().(byte)
+	server.notifications <- &testNotification{
+		ID:      id,
+		Summary: summary,
+		Body:    body,
+		Actions: actions,
+		Urgency: urgency,
+	}
+	return id, nil
+}
+
+func (server *testNotificationServer) CloseNotification(id uint32) *dbus.Error {
+	return nil
+}
+
+func (server *testNotificationServer) emit(t *testing.T, signal string, values ...interface{}) {
+	t.Helper()
+	if err := server.conn.Emit(desktopNotificationsPath, desktopNotificationsInterface+"."+signal, values...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (server *testNotificationServer) next(t *testing.T) *testNotification {
+	t.Helper()
+	select {
+	case notification := <-server.notifications:
+		return notification
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification was shown")
+		return nil
+	}
+}
+
+func newTestDesktopSink(t *testing.T, capabilities []string) (*DesktopNotificationSink, *testNotificationServer) {
+	t.Helper()
+	address := startTestDBus(t)
+	server := startTestNotificationServer(t, address, capabilities)
+	sink := &DesktopNotificationSink{Address: address}
+	if err := sink.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sink.conn.Close() })
+	return sink, server
+}
+
+func TestDesktopNotificationSinkDeliverNotification(t *testing.T) {
+	sink, server := newTestDesktopSink(t, []string{"body", "body-markup", "actions"})
+	err := sink.DeliverNotification(&Notification{
+		Title:    "Disk full",
+		Body:     "<b>/var</b> & /home",
+		Severity: Severity_Critical,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	notification := server.next(t)
+	if notification.Summary != "Disk full" {
+		t.Errorf("summary = %q, want %q", notification.Summary, "Disk full")
+	}
+	if want := "&lt;b&gt;/var&lt;/b&gt; &amp; /home"; notification.Body != want {
+		t.Errorf("body = %q, want %q", notification.Body, want)
+	}
+	if notification.Urgency != 2 {
+		t.Errorf("urgency = %v, want 2", notification.Urgency)
+	}
+}
+
+func TestDesktopNotificationSinkAskQuestion(t *testing.T) {
+	choice := &Question{
+		Kind: QuestionKind_Choice,
+		Text: "Which one?",
+		Options: []QuestionOption{
+			{Label: "First", Value: "first"},
+			{Label: "Second", Value: "second"},
+		},
+	}
+	tests := []struct {
+		name      string
+		question  *Question
+		signal    string
+		action    string
+		wantValue interface{}
+		wantErr   bool
+	}{
+		{"yes", &Question{Kind: QuestionKind_YesNo, Text: "Deploy?"}, "ActionInvoked", "yes", true, false},
+		{"no", &Question{Kind: QuestionKind_YesNo, Text: "Deploy?"}, "ActionInvoked", "no", false, false},
+		{"choice", choice, "ActionInvoked", "option-1", "second", false},
+		// dismissing must not end the question for the other sinks with a timeout
+		{"dismissed", &Question{Kind: QuestionKind_YesNo, Text: "Deploy?", TimeoutBehavior: TimeoutBehavior_Default, DefaultAnswer: false}, "NotificationClosed", "", nil, true},
+	}
+	sink, server := newTestDesktopSink(t, []string{"body", "actions"})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			type result struct {
+				answer *Answer
+				err    error
+			}
+			results := make(chan result, 1)
+			go func() {
+				answer, err := sink.AskQuestion(ctx, tt.question)
+				results <- result{answer, err}
+			}()
+			notification := server.next(t)
+			if notification.Body != tt.question.Text {
+				t.Errorf("body = %q, want %q", notification.Body, tt.question.Text)
+			}
+			if tt.signal == "ActionInvoked" {
+				server.emit(t, tt.signal, notification.ID, tt.action)
+			} else {
+				server.emit(t, tt.signal, notification.ID, uint32(2))
+			}
+			r := <-results
+			if (r.err != nil) != tt.wantErr {
+				t.Fatalf("AskQuestion() error = %v, want error: %v", r.err, tt.wantErr)
+			}
+			if !tt.wantErr && (r.answer.Value != tt.wantValue || r.answer.TimedOut) {
+				t.Errorf("answer = %v (timed out: %v), want %v", r.answer.Value, r.answer.TimedOut, tt.wantValue)
+			}
+			if ctx.Err() != nil {
+				t.Error("the question was answered by the context timeout")
+			}
+		})
+	}
+}
+
+func TestDesktopNotificationSinkWithoutActions(t *testing.T) {
+	sink, _ := newTestDesktopSink(t, []string{"body"})
+	_, err := sink.AskQuestion(context.Background(), &Question{Kind: QuestionKind_YesNo, Text: "Deploy?"})
+	if err == nil {
+		t.Fatal("AskQuestion succeeded without the actions capability")
+	}
+}
@@ -0,0 +1,89 @@
+package notifier
+
+import (
+	"log"
+	"math/rand"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type interactionListener struct {
+	handler func(interaction *discordgo.InteractionCreate)
+	ID      int64
+}
+
+// DiscordManager shares one gateway connection per bot token between all the
+// Discord sinks using it, and dispatches the interactions received on it.
+type DiscordManager struct {
+	sessions             map[string]*discordgo.Session
+	sessionsMutex        sync.RWMutex
+	listenersMutex       sync.RWMutex
+	interactionListeners map[string][]*interactionListener
+}
+
+func NewDiscordManager() *DiscordManager {
+	return &DiscordManager{
+		sessions:             make(map[string]*discordgo.Session),
+		interactionListeners: make(map[string][]*interactionListener),
+	}
+}
+
+func (d *DiscordManager) RegisterBot(botToken string) (*discordgo.Session, error) {
+	d.sessionsMutex.Lock()
+	defer d.sessionsMutex.Unlock()
+
+	if session, ok := d.sessions[botToken]; ok {
+		return session, nil
+	}
+
+	session, err := discordgo.New("Bot " + botToken)
+	if err != nil {
+		return nil, err
+	}
+	session.Identify.Intents = discordgo.IntentsGuilds
+	session.AddHandler(func(s *discordgo.Session, interaction *discordgo.InteractionCreate) {
+		d.dispatchInteraction(botToken, interaction)
+	})
+	if err := session.Open(); err != nil {
+		return nil, err
+	}
+
+	d.sessions[botToken] = session
+
+	return session, nil
+}
+
+func (d *DiscordManager) AddInteractionListener(botToken string, listener func(interaction *discordgo.InteractionCreate)) func() {
+	d.listenersMutex.Lock()
+	defer d.listenersMutex.Unlock()
+	added := &interactionListener{
+		handler: listener,
+		ID:      rand.Int63(),
+	}
+	d.interactionListeners[botToken] = append(d.interactionListeners[botToken], added)
+	return func() {
+		d.listenersMutex.Lock()
+		defer d.listenersMutex.Unlock()
+		for i, l := range d.interactionListeners[botToken] {
+			if l.ID == added.ID {
+				d.interactionListeners[botToken] = append(d.interactionListeners[botToken][:i], d.interactionListeners[botToken][i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (d *DiscordManager) dispatchInteraction(botToken string, interaction *discordgo.InteractionCreate) {
+	d.listenersMutex.RLock()
+	listeners := append([]*interactionListener{}, d.interactionListeners[botToken]...)
+	d.listenersMutex.RUnlock()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic in discord interaction handler: %v", r)
+		}
+	}()
+	for _, l := range listeners {
+		l.handler(interaction)
+	}
+}
@@ -0,0 +1,280 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	discordEmbedColor     = 0x5865f2
+	discordButtonsPerRow  = 5
+	discordMaxButtonCount = 25
+)
+
+// DiscordNotificationSink delivers notifications as embeds, either through a
+// webhook or with a bot. Questions need the bot, as they are answered with
+// message components.
+type DiscordNotificationSink struct {
+	WebhookURL     string
+	BotToken       string
+	ChannelID      string
+	AllowedUsers   []string // user IDs, anyone in the channel can answer when empty
+	DiscordManager *DiscordManager
+	QuestionStore  *QuestionStore
+	session        *discordgo.Session
+	client         *http.Client
+}
+
+func (sink *DiscordNotificationSink) Init() error {
+	if sink.WebhookURL == "" && (sink.BotToken == "" || sink.ChannelID == "") {
+		return fmt.Errorf("either webhook_url or bot_token and channel_id must be set")
+	}
+	// usernames can be changed and then claimed by someone else, IDs are permanent
+	for _, allowed := range sink.AllowedUsers {
+		if _, err := strconv.ParseUint(allowed, 10, 64); err != nil {
+			return fmt.Errorf("allowed_users must be numeric user IDs, got %q", allowed)
+		}
+	}
+	if sink.BotToken != "" {
+		session, err := sink.DiscordManager.RegisterBot(sink.BotToken)
+		if err != nil {
+			return err
+		}
+		sink.session = session
+	}
+	sink.client = &http.Client{
+		Timeout: 10 * time.Second,
+	}
+	log.Printf("Successfully initialized %T", *sink)
+	return nil
+}
+
+func (sink *DiscordNotificationSink) DeliverNotification(notification *Notification) error {
+	embed := &discordgo.MessageEmbed{
+		Title:       truncateText(notification.Title, 256),
+		Description: truncateText(notification.Body, 4096),
+		Timestamp:   notification.Timestamp.Format(time.RFC3339),
+		Color:       discordEmbedColor,
+	}
+	if sink.session != nil {
+		_, err := sink.session.ChannelMessageSendEmbed(sink.ChannelID, embed)
+		return err
+	}
+	return sink.executeWebhook(&discordgo.WebhookParams{
+		Embeds: []*discordgo.MessageEmbed{embed},
+	})
+}
+
+func (sink *DiscordNotificationSink) executeWebhook(params *discordgo.WebhookParams) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	resp, err := sink.client.Post(sink.WebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("discord responded with status %v: %v", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+func (sink *DiscordNotificationSink) AskQuestion(ctx context.Context, question *Question) (*Answer, error) {
+	if sink.session == nil {
+		return nil, fmt.Errorf("bot_token and channel_id must be set to ask questions")
+	}
+	labels := []string{}
+	values := []interface{}{}
+	switch question.Kind {
+	case QuestionKind_YesNo:
+		labels = append(labels, "Yes", "No")
+		values = append(values, true, false)
+	case QuestionKind_Choice:
+		if len(question.Options) > discordMaxButtonCount {
+			return nil, fmt.Errorf("discord supports at most %v options", discordMaxButtonCount)
+		}
+		for _, option := range question.Options {
+			labels = append(labels, option.Label)
+			values = append(values, option.Value)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported question kind: %v", question.Kind)
+	}
+
+	questionID := fmt.Sprintf("%x", rand.Int63())
+	customIDs := []string{}
+	rows := []discordgo.MessageComponent{}
+	row := discordgo.ActionsRow{}
+	for i, label := range labels {
+		customIDs = append(customIDs, fmt.Sprintf("%v_%v", i, questionID))
+		style := discordgo.SecondaryButton
+		if values[i] == true {
+			style = discordgo.SuccessButton
+		} else if values[i] == false {
+			style = discordgo.DangerButton
+		}
+		row.Components = append(row.Components, discordgo.Button{
+			Label:    truncateText(label, 80),
+			Style:    style,
+			CustomID: customIDs[i],
+		})
+		if len(row.Components) == discordButtonsPerRow || i == len(labels)-1 {
+			rows = append(rows, row)
+			row = discordgo.ActionsRow{}
+		}
+	}
+	msgSent, err := sink.session.ChannelMessageSendComplex(sink.ChannelID, &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{discordQuestionEmbed(question, "")},
+		Components: rows,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to send question: %v", err)
+	}
+	sink.QuestionStore.recordMessage(question.ID, &StoredQuestionMessage{
+		SinkID:  sink.SinkID(),
+		Channel: msgSent.ChannelID,
+		Message: msgSent.ID,
+	})
+	questionAskedTime := time.Now()
+	answerChan := make(chan *Answer, 1)
+	removeListener := sink.DiscordManager.AddInteractionListener(sink.BotToken, func(interaction *discordgo.InteractionCreate) {
+		if interaction.Type != discordgo.InteractionMessageComponent || interaction.ChannelID != sink.ChannelID {
+			return
+		}
+		customID := interaction.MessageComponentData().CustomID
+		for i := range customIDs {
+			if customID != customIDs[i] {
+				continue
+			}
+			user := interaction.User
+			if interaction.Member != nil {
+				user = interaction.Member.User
+			}
+			if !sink.isAllowedToAnswer(user) {
+				err := sink.session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+					Type: discordgo.InteractionResponseChannelMessageWithSource,
+					Data: &discordgo.InteractionResponseData{
+						Content: "You are not allowed to answer this question",
+						Flags:   uint64(discordgo.MessageFlagsEphemeral),
+					},
+				})
+				if err != nil {
+					log.Printf("failed to respond to discord interaction: %v", err)
+				}
+				return
+			}
+			err := sink.session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseUpdateMessage,
+				Data: &discordgo.InteractionResponseData{
+					Embeds:     []*discordgo.MessageEmbed{discordQuestionEmbed(question, fmt.Sprintf("Answered: %v (by %v)", labels[i], user.Username))},
+					Components: []discordgo.MessageComponent{},
+				},
+			})
+			if err != nil {
+				log.Printf("failed to respond to discord interaction: %v", err)
+			}
+			select {
+			case answerChan <- &Answer{
+				Value:          values[i],
+				AnwserDuration: time.Since(questionAskedTime),
+				AnsweredBy: &Answerer{
+					Sink:        "discord",
+					ID:          user.ID,
+					Username:    user.Username,
+					DisplayName: user.String(),
+				},
+				AnsweredAt: time.Now(),
+			}:
+			default:
+			}
+			return
+		}
+	})
+	defer removeListener()
+
+	select {
+	case answer := <-answerChan:
+		return answer, nil
+	case <-ctx.Done():
+		edit := discordgo.NewMessageEdit(msgSent.ChannelID, msgSent.ID).SetEmbed(discordQuestionEmbed(question, question.TimeoutLabel()))
+		edit.Components = []discordgo.MessageComponent{}
+		if _, err := sink.session.ChannelMessageEditComplex(edit); err != nil {
+			log.Printf("failed to edit message after question timeout: %v", err)
+		}
+		return &Answer{
+			Value:          question.TimeoutValue(),
+			TimedOut:       true,
+			AnwserDuration: time.Since(questionAskedTime),
+		}, nil
+	}
+}
+
+// discordQuestionEmbed shows the question, with the outcome in the footer once there is one.
+func discordQuestionEmbed(question *Question, footer string) *discordgo.MessageEmbed {
+	embed := &discordgo.MessageEmbed{
+		Description: truncateText(question.Text, 4096),
+		Timestamp:   question.Timestamp.Format(time.RFC3339),
+		Color:       discordEmbedColor,
+	}
+	if footer != "" {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: footer}
+	}
+	return embed
+}
+
+// SinkID is based on the channel only, since the bot user is not known
+// before the gateway is ready. Channel IDs are unique across all servers.
+func (sink *DiscordNotificationSink) SinkID() string {
+	return fmt.Sprintf("discord:%v", sink.ChannelID)
+}
+
+// ExpireQuestion removes the buttons of a question message left over from a
+// previous run.
+func (sink *DiscordNotificationSink) ExpireQuestion(question *Question, message *StoredQuestionMessage) error {
+	edit := discordgo.NewMessageEdit(message.Channel, message.Message).SetEmbed(discordQuestionEmbed(question, questionExpiredLabel))
+	edit.Components = []discordgo.MessageComponent{}
+	_, err := sink.session.ChannelMessageEditComplex(edit)
+	return err
+}
+
+func (sink *DiscordNotificationSink) isAllowedToAnswer(user *discordgo.User) bool {
+	if user == nil {
+		return false
+	}
+	if len(sink.AllowedUsers) == 0 {
+		return true
+	}
+	for _, allowed := range sink.AllowedUsers {
+		if allowed == user.ID {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	registerSinkType("discord", func(config sinkConfig, managers *sinkManagers) (NotificationSink, error) {
+		return &DiscordNotificationSink{
+			WebhookURL:     config.String("webhook_url"),
+			BotToken:       config.String("bot_token"),
+			ChannelID:      config.String("channel_id"),
+			AllowedUsers:   config.Strings("allowed_users"),
+			DiscordManager: managers.Discord,
+			QuestionStore:  managers.QuestionStore,
+		}, nil
+	})
+}
@@ -0,0 +1,85 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestDiscordNotificationSinkWebhook(t *testing.T) {
+	var received discordgo.WebhookParams
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %v, want POST", r.Method)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := &DiscordNotificationSink{WebhookURL: server.URL, DiscordManager: NewDiscordManager()}
+	if err := sink.Init(); err != nil {
+		t.Fatal(err)
+	}
+	timestamp := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	if err := sink.DeliverNotification(&Notification{Title: "Backup", Body: "finished", Timestamp: timestamp}); err != nil {
+		t.Fatal(err)
+	}
+	if len(received.Embeds) != 1 {
+		t.Fatalf("got %v embeds, want 1", len(received.Embeds))
+	}
+	embed := received.Embeds[0]
+	if embed.Title != "Backup" || embed.Description != "finished" || embed.Timestamp != "2021-10-01T12:00:00Z" {
+		t.Errorf("embed = %+v", embed)
+	}
+
+	status = http.StatusTooManyRequests
+	if err := sink.DeliverNotification(&Notification{Body: "again"}); err == nil {
+		t.Error("DeliverNotification() succeeded on a 429 response")
+	}
+}
+
+func TestDiscordNotificationSinkQuestionsNeedBot(t *testing.T) {
+	sink := &DiscordNotificationSink{WebhookURL: "https://discord.invalid/api/webhooks/1/token", DiscordManager: NewDiscordManager()}
+	if err := sink.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sink.AskQuestion(context.Background(), &Question{Kind: QuestionKind_YesNo, Text: "Deploy?"}); err == nil {
+		t.Error("AskQuestion succeeded without a bot")
+	}
+}
+
+func TestDiscordNotificationSinkAllowedUsers(t *testing.T) {
+	tests := []struct {
+		allowed string
+		wantErr bool
+	}{
+		{"123456789012345678", false},
+		{"alice", true},
+		{"@alice", true},
+		{"alice#1234", true},
+	}
+	for _, tt := range tests {
+		sink := &DiscordNotificationSink{WebhookURL: "https://discord.invalid/api/webhooks/1/token", AllowedUsers: []string{tt.allowed}, DiscordManager: NewDiscordManager()}
+		if err := sink.Init(); (err != nil) != tt.wantErr {
+			t.Errorf("Init() with allowed user %q = %v, want error: %v", tt.allowed, err, tt.wantErr)
+		}
+	}
+
+	// a username equal to an allowed ID does not match
+	sink := &DiscordNotificationSink{AllowedUsers: []string{"123456789012345678"}}
+	if sink.isAllowedToAnswer(&discordgo.User{ID: "1", Username: "123456789012345678"}) {
+		t.Error("a user was allowed by their username")
+	}
+	if !sink.isAllowedToAnswer(&discordgo.User{ID: "123456789012345678", Username: "alice"}) {
+		t.Error("the allowed user ID was refused")
+	}
+}
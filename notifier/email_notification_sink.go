@@ -1,11 +1,15 @@
 package notifier
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"log"
+	"mime"
+	"net/mail"
 	"net/smtp"
 	"strings"
+	"time"
 )
 
 type EmailNotificationSink struct {
@@ -15,9 +19,26 @@ type EmailNotificationSink struct {
 	SMTPUsername string
 	SMTPPassword string
 	StartTLS     bool
+	AnswerLinks  *AnswerLinkManager
+	from         *mail.Address
+	to           map[string]*mail.Address
 }
 
 func (sink *EmailNotificationSink) Init() error {
+	// the addresses end up in the headers, parsing them rejects line breaks
+	from, err := mail.ParseAddress(sink.From)
+	if err != nil {
+		return fmt.Errorf("invalid from address %q: %w", sink.From, err)
+	}
+	sink.from = from
+	sink.to = make(map[string]*mail.Address)
+	for _, to := range sink.To {
+		addr, err := mail.ParseAddress(to)
+		if err != nil {
+			return fmt.Errorf("invalid to address %q: %w", to, err)
+		}
+		sink.to[to] = addr
+	}
 	conn, err := smtp.Dial(sink.SMTPAddress)
 	if err != nil {
 		return fmt.Errorf("failed to connect to SMTP server %v: %w", sink.SMTPAddress, err)
@@ -43,13 +64,7 @@ func (sink *EmailNotificationSink) DeliverNotification(notification *Notificatio
 	for _, to := range sink.To {
 
 		body := fmt.Sprintf("%v\n\n\n%v", notification.Body, formatDate(notification.Timestamp))
-		err := smtp.SendMail(
-			sink.SMTPAddress,
-			sink.getAuth(),
-			sink.From,
-			[]string{to},
-			[]byte(fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s", sink.From, to, notification.Title, body)),
-		)
+		err := sink.sendMail(to, notification.Title, body)
 		if err != nil {
 			errors = append(errors, err)
 		}
@@ -64,6 +79,128 @@ func (sink *EmailNotificationSink) DeliverNotification(notification *Notificatio
 	return nil
 }
 
+// AskQuestion sends every recipient a message with links which answer the
+// question when opened. The links point to the HTTP server at http.public_url.
+func (sink *EmailNotificationSink) AskQuestion(ctx context.Context, question *Question) (*Answer, error) {
+	if sink.AnswerLinks == nil {
+		return nil, fmt.Errorf("answer links are not available")
+	}
+	expires, ok := ctx.Deadline()
+	if !ok {
+		expires = time.Now().Add(time.Hour * 100000)
+	}
+	questionAskedTime := time.Now()
+	answerChan := make(chan *Answer, 1)
+	listenerID, removeListener := sink.AnswerLinks.AddListener(question, func(answer *Answer) {
+		select {
+		case answerChan <- answer:
+		default:
+		}
+	})
+	defer removeListener()
+
+	errors := []error{}
+	for _, to := range sink.To {
+		links, err := sink.answerLinks(listenerID, question, to, expires)
+		if err != nil {
+			return nil, err
+		}
+		body := fmt.Sprintf("%v\n\n%v\n\n%v", question.Text, links, formatDate(question.Timestamp))
+		if err := sink.sendMail(to, "Question: "+truncateText(question.Text, 60), body); err != nil {
+			errors = append(errors, err)
+		}
+	}
+	if len(errors) == len(sink.To) {
+		contents := []string{}
+		for _, err := range errors {
+			contents = append(contents, err.Error())
+		}
+		return nil, fmt.Errorf("failed to send question: %v", strings.Join(contents, ",\n"))
+	}
+
+	select {
+	case answer := <-answerChan:
+		return answer, nil
+	case <-ctx.Done():
+		return &Answer{
+			Value:          question.TimeoutValue(),
+			TimedOut:       true,
+			AnwserDuration: time.Since(questionAskedTime),
+		}, nil
+	}
+}
+
+// answerLinks renders the list of links answering the question for one recipient.
+func (sink *EmailNotificationSink) answerLinks(listenerID string, question *Question, to string, expires time.Time) (string, error) {
+	labels := []string{}
+	values := []interface{}{}
+	switch question.Kind {
+	case QuestionKind_YesNo:
+		labels = append(labels, "Yes", "No")
+		values = append(values, true, false)
+	case QuestionKind_Choice:
+		for _, option := range question.Options {
+			labels = append(labels, option.Label)
+			values = append(values, option.Value)
+		}
+	case QuestionKind_Text:
+		labels = append(labels, "Answer")
+		values = append(values, nil)
+	default:
+		return "", fmt.Errorf("unsupported question kind: %v", question.Kind)
+	}
+	answerer := &Answerer{
+		Sink:     "email",
+		ID:       to,
+		Username: to,
+	}
+	lines := []string{}
+	for i, label := range labels {
+		link, err := sink.AnswerLinks.CreateLink(listenerID, values[i], answerer, expires)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, fmt.Sprintf("%v: %v", label, link))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func (sink *EmailNotificationSink) sendMail(to string, subject string, body string) error {
+	addr := sink.to[to]
+	return smtp.SendMail(
+		sink.SMTPAddress,
+		sink.getAuth(),
+		sink.from.Address,
+		[]string{addr.Address},
+		emailMessage(sink.from, addr, subject, body),
+	)
+}
+
+// emailMessage renders a plain text message. The subject is folded into a
+// single line and encoded when it is not ASCII, so that the text of a
+// notification can't add headers.
+func emailMessage(from *mail.Address, to *mail.Address, subject string, body string) []byte {
+	subject = mime.QEncoding.Encode("utf-8", strings.Join(strings.Fields(subject), " "))
+	return []byte(fmt.Sprintf(
+		"From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s",
+		from, to, subject, body,
+	))
+}
+
 func (sink *EmailNotificationSink) getAuth() smtp.Auth {
 	return smtp.PlainAuth("", sink.SMTPUsername, sink.SMTPPassword, strings.Split(sink.SMTPAddress, ":")[0])
 }
+
+func init() {
+	registerSinkType("email", func(config sinkConfig, managers *sinkManagers) (NotificationSink, error) {
+		return &EmailNotificationSink{
+			From:         config.String("from"),
+			To:           config.Strings("to"),
+			SMTPAddress:  config.String("smtp_address"),
+			SMTPUsername: config.String("smtp_username"),
+			SMTPPassword: config.String("smtp_password"),
+			StartTLS:     config.Bool("starttls"),
+			AnswerLinks:  managers.AnswerLinks,
+		}, nil
+	})
+}
@@ -0,0 +1,61 @@
+package notifier
+
+import (
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestEmailMessage(t *testing.T) {
+	from := &mail.Address{Name: "Notifier", Address: "notifier@example.com"}
+	to := &mail.Address{Address: "me@example.com"}
+	tests := []struct {
+		name        string
+		subject     string
+		wantSubject string
+	}{
+		{"ascii", "Question: Deploy?", "Question: Deploy?"},
+		{"line breaks", "Deploy?\r\nBcc: victim@example.com", "Deploy? Bcc: victim@example.com"},
+		{"bare newline", "Deploy?\nX-Injected: 1", "Deploy? X-Injected: 1"},
+		{"non-ascii", "Wdrożyć?", "=?utf-8?q?Wdro=C5=BCy=C4=87=3F?="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := mail.ReadMessage(strings.NewReader(string(emailMessage(from, to, tt.subject, "body\n"))))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := msg.Header.Get("Subject"); got != tt.wantSubject {
+				t.Errorf("Subject = %q, want %q", got, tt.wantSubject)
+			}
+			if len(msg.Header) != 5 {
+				t.Errorf("got the headers %v, want From, To, Subject, MIME-Version and Content-Type", msg.Header)
+			}
+			if msg.Header.Get("From") != `"Notifier" <notifier@example.com>` || msg.Header.Get("To") != "<me@example.com>" {
+				t.Errorf("From = %q, To = %q", msg.Header.Get("From"), msg.Header.Get("To"))
+			}
+		})
+	}
+}
+
+func TestEmailNotificationSinkInitAddresses(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   []string
+	}{
+		{"line break in from", "notifier@example.com\r\nBcc: victim@example.com", []string{"me@example.com"}},
+		{"line break in to", "notifier@example.com", []string{"me@example.com\r\nBcc: victim@example.com"}},
+		{"invalid to", "notifier@example.com", []string{"me"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the addresses are checked before connecting to the SMTP server
+			sink := &EmailNotificationSink{From: tt.from, To: tt.to, SMTPAddress: "smtp.invalid:587"}
+			err := sink.Init()
+			if err == nil || !strings.Contains(err.Error(), "address") {
+				t.Errorf("Init() = %v, want an invalid address error", err)
+			}
+		})
+	}
+}
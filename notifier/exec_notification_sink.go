@@ -0,0 +1,107 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// execWaitDelay is how long to wait for the output of a killed command. A
+// process which left the process group may keep stderr open indefinitely.
+const execWaitDelay = 2 * time.Second
+
+// ExecNotificationSink runs a command for every notification. The command
+// gets the notification as JSON on its stdin, and its title, body and
+// timestamp in the NOTIFIER_TITLE, NOTIFIER_BODY and NOTIFIER_TIMESTAMP
+// environment variables.
+type ExecNotificationSink struct {
+	Command []string // the program followed by its arguments
+	Env     map[string]string
+	Timeout time.Duration
+}
+
+func (sink *ExecNotificationSink) Init() error {
+	if len(sink.Command) == 0 || sink.Command[0] == "" {
+		return fmt.Errorf("command is not set")
+	}
+	if _, err := exec.LookPath(sink.Command[0]); err != nil {
+		return fmt.Errorf("command not found: %w", err)
+	}
+	if sink.Timeout <= 0 {
+		sink.Timeout = 10 * time.Second
+	}
+	log.Printf("Successfully initialized %T", *sink)
+	return nil
+}
+
+func (sink *ExecNotificationSink) DeliverNotification(notification *Notification) error {
+	input, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(sink.Command[0], sink.Command[1:]...)
+	// a shell leaves its children running when it gets killed, so the
+	// command gets its own process group which is killed as a whole
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Env = append(os.Environ(),
+		"NOTIFIER_TITLE="+notification.Title,
+		"NOTIFIER_BODY="+notification.Body,
+		"NOTIFIER_TIMESTAMP="+notification.Timestamp.Format(time.RFC3339),
+	)
+	for k, v := range sink.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("%v failed: %v", sink.Command[0], err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	timer := time.NewTimer(sink.Timeout)
+	defer timer.Stop()
+	select {
+	case err = <-done:
+	case <-timer.C:
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		select {
+		case <-done:
+		case <-time.After(execWaitDelay):
+		}
+		return fmt.Errorf("%v timed out after %v", sink.Command[0], sink.Timeout)
+	}
+	if err != nil {
+		if output := strings.TrimSpace(stderr.String()); output != "" {
+			return fmt.Errorf("%v failed: %v: %v", sink.Command[0], err, truncateText(output, 512))
+		}
+		return fmt.Errorf("%v failed: %v", sink.Command[0], err)
+	}
+	return nil
+}
+
+func init() {
+	registerSinkType("exec", func(config sinkConfig, managers *sinkManagers) (NotificationSink, error) {
+		timeout, err := config.Duration("timeout")
+		if err != nil {
+			return nil, err
+		}
+		command := config.Strings("command")
+		if _, ok := config["command"].(string); ok {
+			command = []string{"/bin/sh", "-c", command[0]}
+		}
+		return &ExecNotificationSink{
+			Command: command,
+			Env:     config.StringMap("env"),
+			Timeout: timeout,
+		}, nil
+	})
+}
@@ -0,0 +1,89 @@
+package notifier
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestExecSink(t *testing.T, script string, env map[string]string, timeout time.Duration) *ExecNotificationSink {
+	t.Helper()
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("/bin/sh is not available")
+	}
+	sink := &ExecNotificationSink{
+		Command: []string{"/bin/sh", "-c", script},
+		Env:     env,
+		Timeout: timeout,
+	}
+	if err := sink.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return sink
+}
+
+func TestExecNotificationSinkDeliverNotification(t *testing.T) {
+	dir := t.TempDir()
+	script := `printf '%s|%s|%s|%s' "$NOTIFIER_TITLE" "$NOTIFIER_BODY" "$NOTIFIER_TIMESTAMP" "$EXTRA" > "$OUT_DIR/env"; cat > "$OUT_DIR/stdin"`
+	sink := newTestExecSink(t, script, map[string]string{"OUT_DIR": dir, "EXTRA": "from config"}, 5*time.Second)
+	timestamp := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+	err := sink.DeliverNotification(&Notification{
+		Title:     "Disk full",
+		Body:      "/var is at 99%",
+		Timestamp: timestamp,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := os.ReadFile(filepath.Join(dir, "env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Disk full|/var is at 99%|2021-05-01T12:00:00Z|from config"; string(env) != want {
+		t.Errorf("environment = %q, want %q", env, want)
+	}
+	stdin, err := os.ReadFile(filepath.Join(dir, "stdin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var notification Notification
+	if err := json.Unmarshal(stdin, &notification); err != nil {
+		t.Fatalf("stdin is not a notification: %v: %q", err, stdin)
+	}
+	if notification.Title != "Disk full" || !notification.Timestamp.Equal(timestamp) {
+		t.Errorf("stdin = %q", stdin)
+	}
+}
+
+func TestExecNotificationSinkErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		wantErr string
+	}{
+		{"non-zero exit", "echo 'no route to host' >&2; exit 3", "exit status 3: no route to host"},
+		{"non-zero exit without output", "exit 1", "exit status 1"},
+		// sleep is a child of the shell and keeps stderr open, it has to be killed too
+		{"timeout", "sleep 30; echo done", "timed out after 200ms"},
+		{"timeout with a background child", "sleep 30 & wait", "timed out after 200ms"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := newTestExecSink(t, tt.script, nil, 200*time.Millisecond)
+			start := time.Now()
+			err := sink.DeliverNotification(&Notification{Title: "Disk full"})
+			if err == nil {
+				t.Fatal("DeliverNotification succeeded")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > execWaitDelay {
+				t.Errorf("DeliverNotification took %v", elapsed)
+			}
+		})
+	}
+}
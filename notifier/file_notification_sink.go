@@ -0,0 +1,222 @@
+package notifier
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	FileRotation_None  = ""
+	FileRotation_Size  = "size"
+	FileRotation_Daily = "daily"
+)
+
+// FileNotificationSink appends every notification as a line of JSON to a
+// file. The file can be rotated when it grows over MaxSize or when the day
+// changes, and the rotated files can be compressed with gzip.
+type FileNotificationSink struct {
+	Path     string
+	Rotation string // "size", "daily" or empty for no rotation
+	MaxSize  int64  // in bytes, used with size rotation
+	Compress bool
+	mutex    sync.Mutex
+	file     *os.File
+	size     int64
+	day      string
+}
+
+func (sink *FileNotificationSink) Init() error {
+	if sink.Path == "" {
+		return fmt.Errorf("path is not set")
+	}
+	switch sink.Rotation {
+	case FileRotation_None, FileRotation_Daily:
+	case FileRotation_Size:
+		if sink.MaxSize <= 0 {
+			return fmt.Errorf("max_size must be set for size rotation")
+		}
+	default:
+		return fmt.Errorf("unknown rotation: %v", sink.Rotation)
+	}
+	if err := sink.open(); err != nil {
+		return err
+	}
+	log.Printf("Successfully initialized file sink %v", sink.Path)
+	return nil
+}
+
+func (sink *FileNotificationSink) open() error {
+	file, err := os.OpenFile(sink.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to open %v: %w", sink.Path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	sink.file = file
+	sink.size = info.Size()
+	sink.day = fileRotationDay(time.Now())
+	if info.Size() > 0 {
+		sink.day = fileRotationDay(info.ModTime())
+	}
+	return nil
+}
+
+func fileRotationDay(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+func (sink *FileNotificationSink) DeliverNotification(notification *Notification) error {
+	line, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if sink.shouldRotate(int64(len(line))) {
+		if err := sink.rotate(); err != nil {
+			return fmt.Errorf("failed to rotate %v: %w", sink.Path, err)
+		}
+	}
+	n, err := sink.file.Write(line)
+	sink.size += int64(n)
+	return err
+}
+
+func (sink *FileNotificationSink) shouldRotate(lineSize int64) bool {
+	if sink.size == 0 {
+		return false
+	}
+	switch sink.Rotation {
+	case FileRotation_Size:
+		return sink.size+lineSize > sink.MaxSize
+	case FileRotation_Daily:
+		return sink.day != fileRotationDay(time.Now())
+	}
+	return false
+}
+
+// rotate renames the current file and opens a new one in its place. Size
+// rotated files are suffixed with the time of the rotation, daily rotated
+// files with the day they contain.
+func (sink *FileNotificationSink) rotate() error {
+	if err := sink.file.Close(); err != nil {
+		return err
+	}
+	suffix := sink.day
+	if sink.Rotation == FileRotation_Size {
+		suffix = time.Now().Format("2006-01-02T15-04-05")
+	}
+	rotatedPath := sink.Path + "." + suffix
+	for i := 1; fileExists(rotatedPath) || fileExists(rotatedPath+".gz"); i++ {
+		rotatedPath = sink.Path + "." + suffix + "." + strconv.Itoa(i)
+	}
+	if err := os.Rename(sink.Path, rotatedPath); err != nil {
+		// keep appending to the old file rather than losing notifications
+		if openErr := sink.open(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+	if sink.Compress {
+		go func() {
+			if err := gzipFile(rotatedPath); err != nil {
+				log.Printf("failed to compress %v: %v", rotatedPath, err)
+			}
+		}()
+	}
+	return sink.open()
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// gzipFile replaces the file with a gzip compressed copy with the .gz extension.
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
+
+// parseByteSize parses sizes like 1048576, "512KB", "10MB" or "1GB".
+func parseByteSize(v interface{}) (int64, error) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case string:
+		s := strings.ToUpper(strings.TrimSpace(v))
+		multiplier := int64(1)
+		for _, unit := range []struct {
+			suffix     string
+			multiplier int64
+		}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+			if strings.HasSuffix(s, unit.suffix) {
+				s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+				multiplier = unit.multiplier
+				break
+			}
+		}
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid size %q", v)
+		}
+		return n * multiplier, nil
+	}
+	return 0, fmt.Errorf("invalid size %v", v)
+}
+
+func init() {
+	registerSinkType("file", func(config sinkConfig, managers *sinkManagers) (NotificationSink, error) {
+		s := &FileNotificationSink{
+			Path:     config.String("path"),
+			Rotation: config.String("rotation"),
+			Compress: config.Bool("compress"),
+		}
+		if config["max_size"] != nil {
+			size, err := parseByteSize(config["max_size"])
+			if err != nil {
+				return nil, fmt.Errorf("invalid max_size: %w", err)
+			}
+			s.MaxSize = size
+		}
+		return s, nil
+	})
+}
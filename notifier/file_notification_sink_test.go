@@ -0,0 +1,127 @@
+package notifier
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readNotificationTitles returns the titles of the notifications in a file
+// written by FileNotificationSink, which may be compressed with gzip.
+func readNotificationTitles(t *testing.T, path string) []string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatalf("%v is not gzip compressed: %v", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	titles := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var notification Notification
+		if err := json.Unmarshal(scanner.Bytes(), &notification); err != nil {
+			t.Fatalf("invalid line in %v: %v", path, err)
+		}
+		titles = append(titles, notification.Title)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return titles
+}
+
+// waitForCompressedFiles waits until the rotated files have been compressed.
+func waitForCompressedFiles(t *testing.T, path string, count int) []string {
+	t.Helper()
+	for i := 0; i < 500; i++ {
+		rotated, err := filepath.Glob(path + ".*")
+		if err != nil {
+			t.Fatal(err)
+		}
+		compressed := []string{}
+		for _, p := range rotated {
+			if strings.HasSuffix(p, ".gz") {
+				compressed = append(compressed, p)
+			}
+		}
+		if len(rotated) == count && len(compressed) == count {
+			sort.Strings(compressed)
+			return compressed
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("the rotated files of %v were not compressed", path)
+	return nil
+}
+
+func TestFileNotificationSinkSizeRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.log")
+	sink := &FileNotificationSink{
+		Path:     path,
+		Rotation: FileRotation_Size,
+		MaxSize:  100,
+		Compress: true,
+	}
+	if err := sink.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sink.file.Close() })
+	// every notification is larger than half of max_size, so each one rotates the file
+	for _, title := range []string{"first", "second", "third"} {
+		err := sink.DeliverNotification(&Notification{Title: title, Body: "the disk is full", Timestamp: time.Now()})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	compressed := waitForCompressedFiles(t, path, 2)
+	got := []string{}
+	for _, p := range compressed {
+		got = append(got, readNotificationTitles(t, p)...)
+	}
+	sort.Strings(got)
+	if strings.Join(got, ",") != "first,second" {
+		t.Errorf("rotated files contain %v, want first and second", got)
+	}
+	if current := readNotificationTitles(t, path); len(current) != 1 || current[0] != "third" {
+		t.Errorf("%v contains %v, want third", path, current)
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		value   interface{}
+		want    int64
+		wantErr bool
+	}{
+		{1048576, 1048576, false},
+		{"512KB", 512 << 10, false},
+		{"10 mb", 10 << 20, false},
+		{"1GB", 1 << 30, false},
+		{"100B", 100, false},
+		{"ten MB", 0, true},
+		{1.5, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseByteSize(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseByteSize(%v) = %v, %v, want %v (error: %v)", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
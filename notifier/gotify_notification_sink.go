@@ -0,0 +1,87 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// GotifyNotificationSink sends notifications to a Gotify server using an application token.
+type GotifyNotificationSink struct {
+	ServerURL string
+	AppToken  string
+	Priority  *int     // 0 to 10, the default priority of the application when nil
+	Tags      []string // gotify has no tags, they are prepended to the title as hashtags
+	client    *http.Client
+}
+
+type gotifyMessage struct {
+	Title    string `json:"title,omitempty"`
+	Message  string `json:"message"`
+	Priority *int   `json:"priority,omitempty"`
+}
+
+func (sink *GotifyNotificationSink) Init() error {
+	if sink.ServerURL == "" || sink.AppToken == "" {
+		return fmt.Errorf("server_url and app_token must be set")
+	}
+	if sink.Priority != nil && (*sink.Priority < 0 || *sink.Priority > 10) {
+		return fmt.Errorf("priority must be between 0 and 10")
+	}
+	sink.ServerURL = strings.TrimSuffix(sink.ServerURL, "/")
+	sink.client = &http.Client{
+		Timeout: 10 * time.Second,
+	}
+	log.Printf("Successfully initialized %T", *sink)
+	return nil
+}
+
+func (sink *GotifyNotificationSink) DeliverNotification(notification *Notification) error {
+	title := notification.Title
+	for i := len(sink.Tags) - 1; i >= 0; i-- {
+		title = strings.TrimSpace("#" + sink.Tags[i] + " " + title)
+	}
+	body, err := json.Marshal(&gotifyMessage{
+		Title:    title,
+		Message:  notification.Body,
+		Priority: sink.Priority,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, sink.ServerURL+"/message", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gotify-Key", sink.AppToken)
+	resp, err := sink.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("gotify responded with status %v: %v", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+func init() {
+	registerSinkType("gotify", func(config sinkConfig, managers *sinkManagers) (NotificationSink, error) {
+		s := &GotifyNotificationSink{
+			ServerURL: config.String("server_url"),
+			AppToken:  config.String("app_token"),
+			Tags:      config.Strings("tags"),
+		}
+		if priority, ok := config["priority"].(int); ok {
+			s.Priority = &priority
+		}
+		return s, nil
+	})
+}
@@ -0,0 +1,47 @@
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGotifyNotificationSink(t *testing.T) {
+	priority := func(p int) *int { return &p }
+	tests := []struct {
+		name         string
+		priority     *int
+		tags         []string
+		wantTitle    string
+		wantPriority interface{}
+	}{
+		{"application default priority", nil, nil, "Disk", nil},
+		{"zero priority", priority(0), nil, "Disk", float64(0)},
+		{"priority and tags", priority(8), []string{"backup", "nas"}, "#backup #nas Disk", float64(8)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received map[string]interface{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/message" || r.Header.Get("X-Gotify-Key") != "app-token" {
+					t.Errorf("unexpected request to %v with key %q", r.URL.Path, r.Header.Get("X-Gotify-Key"))
+				}
+				if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+					t.Error(err)
+				}
+			}))
+			defer server.Close()
+			sink := &GotifyNotificationSink{ServerURL: server.URL, AppToken: "app-token", Priority: tt.priority, Tags: tt.tags}
+			if err := sink.Init(); err != nil {
+				t.Fatal(err)
+			}
+			if err := sink.DeliverNotification(&Notification{Title: "Disk", Body: "full"}); err != nil {
+				t.Fatal(err)
+			}
+			if received["title"] != tt.wantTitle || received["message"] != "full" || received["priority"] != tt.wantPriority {
+				t.Errorf("received %v", received)
+			}
+		})
+	}
+}
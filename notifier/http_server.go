@@ -2,7 +2,11 @@ package notifier
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
 	_ "embed"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
@@ -10,6 +14,7 @@ import (
 	"strings"
 	"time"
 
+	webpush "github.com/SherClockHolmes/webpush-go"
 	swagger "github.com/arsmn/fiber-swagger/v2"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v4"
@@ -26,12 +31,27 @@ import (
 // @name Authorization
 
 type HttpServer struct {
-	router *fiber.App
-	Sinks  []NotificationSink
-	Users  []*User
+	router       *fiber.App
+	Sinks        []*configuredSink
+	Users        []*User
+	Questions    *QuestionRegistry
+	AnswerLinks  *AnswerLinkManager
+	SlackManager *SlackManager
+	Web          *WebNotificationSink
+	WebPush      *WebPushNotificationSink
 }
 
-func NewHttpServer(sinks []NotificationSink, users []*User) *HttpServer {
+func NewHttpServer(sinks []*configuredSink, users []*User, questions *QuestionRegistry, answerLinks *AnswerLinkManager, slackManager *SlackManager) *HttpServer {
+	var web *WebNotificationSink
+	var webPush *WebPushNotificationSink
+	for _, sink := range sinks {
+		switch w := sink.Sink.(type) {
+		case *WebNotificationSink:
+			web = w
+		case *WebPushNotificationSink:
+			webPush = w
+		}
+	}
 	return &HttpServer{
 		router: fiber.New(
 			fiber.Config{
@@ -39,32 +59,57 @@ func NewHttpServer(sinks []NotificationSink, users []*User) *HttpServer {
 				ServerHeader: "Notifier",
 			},
 		),
-		Sinks: sinks,
-		Users: users,
+		Sinks:        sinks,
+		Users:        users,
+		Questions:    questions,
+		AnswerLinks:  answerLinks,
+		SlackManager: slackManager,
+		Web:          web,
+		WebPush:      webPush,
 	}
 }
 
 func (s *HttpServer) Start(addr string) {
+	s.registerRoutes()
+	if err := s.router.Listen(addr); err != nil {
+		log.Fatal(err)
+	}
+}
 
+func (s *HttpServer) registerRoutes() {
 	s.router.Use(s.authorizationMiddleware)
 	s.router.Post("/notify", s.postNotify)
 	s.router.Post("/question", s.postQuestion)
+	s.router.Get("/question/:id", s.getQuestion)
+	s.router.Delete("/question/:id", s.deleteQuestion)
 	s.router.Get("/login", s.getLogin)
 	s.router.Post("/login", s.postLogin)
+	s.router.Get("/ui", s.getUI)
+	s.router.Post("/ui/questions/:id", s.postUIAnswer)
+	s.router.Get("/webpush", s.getWebPush)
+	s.router.Get("/webpush/sw.js", s.getWebPushServiceWorker)
+	s.router.Post("/webpush/subscriptions", s.postWebPushSubscription)
+	s.router.Delete("/webpush/subscriptions", s.deleteWebPushSubscription)
+	s.router.Post("/slack/interactions", s.postSlackInteractions)
+	s.router.Get("/answer/:token", s.getAnswer)
+	s.router.Post("/answer/:token", s.postAnswer)
 	s.router.Get("/*", swagger.Handler) // default
-	if err := s.router.Listen(addr); err != nil {
-		log.Fatal(err)
-	}
 }
 
 func (s *HttpServer) authorizationMiddleware(c *fiber.Ctx) error {
-	if string(c.Request().URI().Path()) == "/login" {
+	path := string(c.Request().URI().Path())
+	// answer links and slack interactions carry their own signatures, the
+	// service worker is fetched by the browser without the login cookie on updates
+	if path == "/login" || path == "/slack/interactions" || path == "/webpush/sw.js" || strings.HasPrefix(path, "/answer/") {
 		return c.Next()
 	}
 	tokenString := c.Cookies("NOTIFIER_TOKEN")
 
 	if tokenString != "" {
 		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+			}
 			return []byte(viper.GetString("http.jwt_secret")), nil
 		})
 		if err != nil {
@@ -73,9 +118,12 @@ func (s *HttpServer) authorizationMiddleware(c *fiber.Ctx) error {
 			})
 		}
 		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			if claims.VerifyExpiresAt(time.Now().Unix(), true) {
+			username, _ := claims["username"].(string)
+			// tokens with an audience are issued for other purposes, like answer links
+			_, hasAudience := claims["aud"]
+			if claims.VerifyExpiresAt(time.Now().Unix(), true) && !hasAudience && username != "" {
 				for _, user := range s.Users {
-					if user.Username == claims["username"].(string) {
+					if user.Username == username {
 						c.Context().SetUserValue("user", user)
 						return c.Next()
 					}
@@ -117,6 +165,240 @@ func (s *HttpServer) getLogin(c *fiber.Ctx) error {
 	return template.Must(template.New("login").Parse(string(loginTemplate))).Execute(c.Response().BodyWriter(), nil)
 }
 
+//go:embed assets/answer.html
+var answerTemplate []byte
+
+func (s *HttpServer) renderAnswerPage(c *fiber.Ctx, data fiber.Map) error {
+	c.Response().Header.Set("Content-Type", "text/html")
+	return template.Must(template.New("answer").Parse(string(answerTemplate))).Execute(c.Response().BodyWriter(), data)
+}
+
+// getAnswer shows a confirmation page for an answer link. The answer is only
+// submitted with a POST, so that link previews do not answer questions.
+func (s *HttpServer) getAnswer(c *fiber.Ctx) error {
+	question, value, err := s.AnswerLinks.Lookup(c.Params("token"))
+	if err != nil {
+		c.Status(fiber.StatusBadRequest)
+		return s.renderAnswerPage(c, fiber.Map{"Error": err.Error()})
+	}
+	label, _ := question.labelForValue(value)
+	return s.renderAnswerPage(c, fiber.Map{
+		"Question":   question,
+		"Label":      label,
+		"NeedsInput": value == nil,
+	})
+}
+
+func (s *HttpServer) postAnswer(c *fiber.Ctx) error {
+	question, _, err := s.AnswerLinks.Lookup(c.Params("token"))
+	if err != nil {
+		c.Status(fiber.StatusBadRequest)
+		return s.renderAnswerPage(c, fiber.Map{"Error": err.Error()})
+	}
+	answer, err := s.AnswerLinks.Resolve(c.Params("token"), c.FormValue("value"))
+	if err != nil {
+		c.Status(fiber.StatusBadRequest)
+		return s.renderAnswerPage(c, fiber.Map{"Error": err.Error(), "Question": question})
+	}
+	label, _ := question.labelForValue(answer.Value)
+	return s.renderAnswerPage(c, fiber.Map{
+		"Question": question,
+		"Label":    label,
+		"Answered": true,
+	})
+}
+
+//go:embed assets/questions.html
+var questionsTemplate []byte
+
+type uiQuestion struct {
+	Question *Question
+	Date     string
+	Options  []QuestionOption
+}
+
+type uiNotification struct {
+	Notification *Notification
+	Date         string
+}
+
+// renderUI renders the page listing the questions waiting for an answer in the web sink.
+func (s *HttpServer) renderUI(c *fiber.Ctx, data fiber.Map) error {
+	if s.Web == nil {
+		data["Error"] = "Add a sink with type: web to the config file to answer questions here."
+	} else {
+		questions := []*uiQuestion{}
+		for _, q := range s.Web.PendingQuestions() {
+			uq := &uiQuestion{Question: q, Date: formatDate(q.Timestamp), Options: q.Options}
+			if q.Kind == QuestionKind_YesNo {
+				uq.Options = []QuestionOption{{Label: "Yes", Value: "true"}, {Label: "No", Value: "false"}}
+			}
+			questions = append(questions, uq)
+		}
+		notifications := []*uiNotification{}
+		for _, n := range s.Web.Notifications() {
+			notifications = append(notifications, &uiNotification{Notification: n, Date: formatDate(n.Timestamp)})
+		}
+		data["Questions"] = questions
+		data["Notifications"] = notifications
+		data["CSRFToken"] = csrfToken(c)
+	}
+	c.Response().Header.Set("Content-Type", "text/html")
+	return template.Must(template.New("questions").Parse(string(questionsTemplate))).Execute(c.Response().BodyWriter(), data)
+}
+
+func (s *HttpServer) getUI(c *fiber.Ctx) error {
+	return s.renderUI(c, fiber.Map{})
+}
+
+// csrfToken returns the token the forms of /ui must send back. It is derived
+// from the login cookie, which other sites can make the browser send but can't read.
+func csrfToken(c *fiber.Ctx) string {
+	mac := hmac.New(sha256.New, []byte(viper.GetString("http.jwt_secret")))
+	mac.Write([]byte("notifier csrf " + c.Cookies("NOTIFIER_TOKEN")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *HttpServer) postUIAnswer(c *fiber.Ctx) error {
+	if s.Web == nil {
+		return s.renderUI(c, fiber.Map{})
+	}
+	// requests authenticated with the Authorization header can't be forged by other sites
+	if c.Cookies("NOTIFIER_TOKEN") != "" && !hmac.Equal([]byte(c.FormValue("csrf_token")), []byte(csrfToken(c))) {
+		c.Status(fiber.StatusForbidden)
+		return s.renderUI(c, fiber.Map{"Error": "The form has expired, please try again."})
+	}
+	user, _ := c.Context().UserValue("user").(*User)
+	var value interface{} = c.FormValue("value")
+	for _, q := range s.Web.PendingQuestions() {
+		if q.ID == c.Params("id") && q.Kind == QuestionKind_YesNo {
+			value = c.FormValue("value") == "true"
+		}
+	}
+	if err := s.Web.Answer(c.Params("id"), user, value); err != nil {
+		if errors.Is(err, errWebQuestionAnswered) {
+			c.Status(fiber.StatusConflict)
+		} else {
+			c.Status(fiber.StatusBadRequest)
+		}
+		return s.renderUI(c, fiber.Map{"Error": err.Error()})
+	}
+	c.Response().Header.Set("Location", "/ui")
+	return c.Status(http.StatusSeeOther).SendString("Answer recorded, redirecting...")
+}
+
+//go:embed assets/webpush.html
+var webPushTemplate []byte
+
+//go:embed assets/webpush-sw.js
+var webPushServiceWorker []byte
+
+// getWebPush serves the page where the users subscribe their browser to the webpush sink.
+func (s *HttpServer) getWebPush(c *fiber.Ctx) error {
+	data := fiber.Map{}
+	if s.WebPush == nil {
+		data["Error"] = "Add a sink with type: webpush to the config file to receive notifications in the browser."
+	} else {
+		data["PublicKey"] = s.WebPush.VAPIDPublicKey
+	}
+	c.Response().Header.Set("Content-Type", "text/html")
+	return template.Must(template.New("webpush").Parse(string(webPushTemplate))).Execute(c.Response().BodyWriter(), data)
+}
+
+func (s *HttpServer) getWebPushServiceWorker(c *fiber.Ctx) error {
+	c.Response().Header.Set("Content-Type", "text/javascript")
+	c.Response().Header.Set("Cache-Control", "no-cache")
+	return c.Send(webPushServiceWorker)
+}
+
+// WebPushSubscriptionBody is a PushSubscription serialized by the browser with toJSON().
+type WebPushSubscriptionBody struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		Auth   string `json:"auth"`
+		P256dh string `json:"p256dh"`
+	} `json:"keys"`
+}
+
+// postWebPushSubscription godoc
+// @Summary Subscribe a browser to web push notifications
+// @Description Registers a PushSubscription created with the VAPID public key of the webpush sink. The /webpush page does it from the browser.
+// @ID post-webpush-subscription
+// @Param subscription body WebPushSubscriptionBody true "PushSubscription to register"
+// @Accept  json
+// @Produce  json
+// @Success 200
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /webpush/subscriptions [post]
+// @Security ApiKeyAuth
+func (s *HttpServer) postWebPushSubscription(c *fiber.Ctx) error {
+	if s.WebPush == nil {
+		return c.Status(fiber.StatusNotFound).JSON(NewErrorResponse(fmt.Errorf("the webpush sink is not configured")))
+	}
+	var body WebPushSubscriptionBody
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse(err))
+	}
+	user, _ := c.Context().UserValue("user").(*User)
+	subscription := &webpush.Subscription{
+		Endpoint: body.Endpoint,
+		Keys: webpush.Keys{
+			Auth:   body.Keys.Auth,
+			P256dh: body.Keys.P256dh,
+		},
+	}
+	if err := s.WebPush.AddSubscription(user, subscription); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse(err))
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// deleteWebPushSubscription godoc
+// @Summary Unsubscribe a browser from web push notifications
+// @Description Removes a subscription of the logged in user, the subscriptions of other users are left untouched.
+// @ID delete-webpush-subscription
+// @Param subscription body WebPushSubscriptionBody true "PushSubscription to remove, only the endpoint is used"
+// @Accept  json
+// @Produce  json
+// @Success 200
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /webpush/subscriptions [delete]
+// @Security ApiKeyAuth
+func (s *HttpServer) deleteWebPushSubscription(c *fiber.Ctx) error {
+	if s.WebPush == nil {
+		return c.Status(fiber.StatusNotFound).JSON(NewErrorResponse(fmt.Errorf("the webpush sink is not configured")))
+	}
+	var body WebPushSubscriptionBody
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse(err))
+	}
+	user, _ := c.Context().UserValue("user").(*User)
+	if err := s.WebPush.RemoveSubscription(user, body.Endpoint); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(NewErrorResponse(err))
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// postSlackInteractions receives the interaction payloads sent by Slack when
+// a button in a question is pressed.
+func (s *HttpServer) postSlackInteractions(c *fiber.Ctx) error {
+	err := s.SlackManager.HandleInteraction(
+		c.Get("X-Slack-Request-Timestamp"),
+		c.Get("X-Slack-Signature"),
+		c.Body(),
+	)
+	if err != nil {
+		log.Printf("Rejected slack interaction: %v", err)
+		if errors.Is(err, errSlackSignature) {
+			return c.Status(fiber.StatusUnauthorized).JSON(NewErrorResponse(err))
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse(err))
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
 type PostLoginBody struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
@@ -143,8 +425,9 @@ func (s *HttpServer) postLogin(c *fiber.Ctx) error {
 				})
 			}
 			c.Cookie(&fiber.Cookie{
-				Name:  "NOTIFIER_TOKEN",
-				Value: tokenString,
+				Name:     "NOTIFIER_TOKEN",
+				Value:    tokenString,
+				SameSite: "Lax",
 			})
 			c.Response().Header.Set("Location", "/")
 
@@ -178,6 +461,14 @@ type PostNotifyResponse struct {
 type PostNotifyBody struct {
 	Title string `json:"title"`
 	Body  string `json:"body"`
+	// Severity is one of "emergency", "alert", "critical", "error", "warning", "notice", "info" or "debug".
+	// Sinks which support it use their configured default when it is empty.
+	Severity string `json:"severity"`
+	// DedupKey identifies the incident in incident management sinks (PagerDuty, Opsgenie).
+	DedupKey string `json:"dedupKey"`
+	// Action is "trigger" (default) or "resolve". Resolving an incident requires a dedupKey
+	// and is only delivered to the incident management sinks.
+	Action string `json:"action"`
 }
 
 // postNotify godoc
@@ -202,18 +493,38 @@ func (s *HttpServer) postNotify(c *fiber.Ctx) error {
 		Timestamp: time.Now(),
 		Title:     body.Title,
 		Body:      body.Body,
+		Severity:  Severity(strings.ToLower(body.Severity)),
+		DedupKey:  body.DedupKey,
+		Action:    NotificationAction(strings.ToLower(body.Action)),
+	}
+	switch notification.Action {
+	case "", NotificationAction_Trigger:
+	case NotificationAction_Resolve:
+		if notification.DedupKey == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse(fmt.Errorf("dedupKey is required to resolve an incident")))
+		}
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse(fmt.Errorf("unknown action: %q", body.Action)))
 	}
-	if notification.Body == "" {
+	if notification.Body == "" && notification.Action != NotificationAction_Resolve {
 		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse(fmt.Errorf("body is empty")))
 	}
+	if notification.Severity != "" {
+		if _, err := notification.Severity.SyslogLevel(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse(err))
+		}
+	}
 	var resp PostNotifyResponse
-	resp.DeliveriesTotal = len(s.Sinks)
 	resp.Errors = make(map[string]string)
 	for _, s := range s.Sinks {
-		if err := s.DeliverNotification(notification); err != nil {
+		if _, ok := s.Sink.(NotificationSinkWithIncidents); !ok && notification.Action == NotificationAction_Resolve {
+			continue
+		}
+		resp.DeliveriesTotal++
+		if err := s.Sink.DeliverNotification(notification); err != nil {
 
-			log.Printf("Delivery with sink %T failed: %v", s, err)
-			resp.Errors[fmt.Sprintf("%T", s)] = err.Error()
+			log.Printf("Delivery with sink %v failed: %v", s.ID, err)
+			resp.Errors[s.ID] = err.Error()
 		} else {
 			resp.DeliveriesSucceeded++
 		}
@@ -222,31 +533,53 @@ func (s *HttpServer) postNotify(c *fiber.Ctx) error {
 }
 
 type PostQuestionBody struct {
-	Text    string        `json:"text"`
-	Kind    string        `json:"kind"`
-	Timeout time.Duration `json:"timeout" swaggertype:"primitive,string"`
+	Text    string           `json:"text"`
+	Kind    string           `json:"kind"`
+	Options []QuestionOption `json:"options"`
+	// Timeout in nanoseconds, 0 means that the question never times out.
+	Timeout time.Duration `json:"timeout" swaggertype:"primitive,integer"`
+	// DefaultAnswer is the value of the answer when the question times out and timeoutBehavior is "default".
+	DefaultAnswer interface{} `json:"defaultAnswer"`
+	// TimeoutBehavior is one of "default", "null" or "error". Defaults to "default" when defaultAnswer
+	// is set, "null" otherwise (yesno questions default to a "No" answer).
+	TimeoutBehavior string `json:"timeoutBehavior"`
+	// Approvals is the number of distinct approvers needed for a yesno question. Any "no" rejects it.
+	Approvals int `json:"approvals"`
+	// RequireAllSinks makes a yesno question require a "yes" from every sink.
+	RequireAllSinks bool `json:"requireAllSinks"`
+	// Async makes the request return the question ID immediately instead of waiting for the answer.
+	Async bool `json:"async"`
+	// CallbackURL receives the final PostQuestionResponse as a signed POST request.
+	CallbackURL string `json:"callbackUrl"`
 }
 
 type PostQuestionResponse struct {
+	ID     string            `json:"id"`
+	Status QuestionStatus    `json:"status" swaggertype:"string"`
 	Errors map[string]string `json:"errors"`
 	Answer *Answer           `json:"answer"`
 }
 
 type sinkResult struct {
-	sinkName string
-	err      error
-	answer   *Answer
+	sinkID string
+	err    error
+	answer *Answer
 }
 
 // postQuestion godoc
 // @Summary Asks a question to the user
-// @Description Currently supported question types: yesno
+// @Description Currently supported question types: yesno, text, choice (requires options).
+// @Description When async is set the response is returned immediately and the answer can be polled with GET /question/{id}.
+// @Description yesno questions with approvals or requireAllSinks resolve once enough distinct people answered "yes", or any of them answered "no". The individual votes are listed in answer.votes.
+// @Description When timeoutBehavior is "error" a timed out question is reported with status 504.
+// @Description When callbackUrl is set the final response is POSTed to it, signed with HMAC-SHA256 using questions.callback_secret in the X-Notifier-Signature header.
 // @ID post-question
 // @Param notification body PostQuestionBody true "Question to ask"
 // @Accept  json
 // @Produce  json
 // @Success 200 {object} PostQuestionResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 504 {object} PostQuestionResponse
 // @Router /question [post]
 // @Security ApiKeyAuth
 func (s *HttpServer) postQuestion(c *fiber.Ctx) error {
@@ -259,61 +592,258 @@ func (s *HttpServer) postQuestion(c *fiber.Ctx) error {
 	if body.Kind == "" {
 		body.Kind = string(QuestionKind_YesNo)
 	}
+	if body.TimeoutBehavior == "" {
+		switch {
+		case body.DefaultAnswer != nil:
+			body.TimeoutBehavior = string(TimeoutBehavior_Default)
+		case body.Kind == string(QuestionKind_YesNo):
+			body.TimeoutBehavior = string(TimeoutBehavior_Default)
+			body.DefaultAnswer = false
+		default:
+			body.TimeoutBehavior = string(TimeoutBehavior_Null)
+		}
+	}
 	question := &Question{
-		Timestamp: time.Now(),
-		Text:      body.Text,
-		Kind:      QuestionKind(body.Kind),
+		ID:              generateID(),
+		Timestamp:       time.Now(),
+		Text:            body.Text,
+		Kind:            QuestionKind(body.Kind),
+		Options:         body.Options,
+		DefaultAnswer:   body.DefaultAnswer,
+		TimeoutBehavior: TimeoutBehavior(body.TimeoutBehavior),
+		Approvals:       body.Approvals,
+		RequireAllSinks: body.RequireAllSinks,
 	}
-	if question.Text == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "text is empty",
-		})
+	if err := question.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse(err))
+	}
+
+	if body.CallbackURL != "" {
+		if err := validateCallbackURL(body.CallbackURL); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse(err))
+		}
 	}
 
-	if body.Timeout < time.Second {
+	if body.Timeout < 0 || (body.Timeout > 0 && body.Timeout < time.Second) {
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse(
+			fmt.Errorf("timeout must be 0 or at least 1s (%v ns), it is given in nanoseconds", int64(time.Second)),
+		))
+	}
+	if body.Timeout == 0 {
 		body.Timeout = time.Hour * 100000
 	}
 
-	ctx, cancel := context.WithTimeout(c.Context(), body.Timeout)
-	go func() {
-		<-c.Context().Done()
-		log.Printf("THE CTX IS DONE NOW")
-	}()
+	// a synchronous question belongs to its request, an asynchronous one
+	// outlives it and ends only when answered, cancelled or timed out
+	parent := context.Background()
+	if !body.Async {
+		parent = c.Context()
+	}
+	ctx, cancel := context.WithTimeout(parent, body.Timeout)
+	user, _ := c.Context().UserValue("user").(*User)
+	username := ""
+	if user != nil {
+		username = user.Username
+	}
+	pq := s.Questions.Add(question, username, time.Now().Add(body.Timeout), body.CallbackURL, cancel)
+	go s.askSinks(ctx, pq)
+	if body.CallbackURL != "" {
+		go func() {
+			<-pq.Done()
+			deliverQuestionCallback(body.CallbackURL, pq.Response())
+		}()
+	}
+
+	if !body.Async {
+		<-pq.Done()
+	}
+	return s.sendQuestionResponse(c, pq)
+}
+
+// sendQuestionResponse responds with the state of the question, using an
+// error status if it timed out and the caller asked for it.
+func (s *HttpServer) sendQuestionResponse(c *fiber.Ctx, pq *PendingQuestion) error {
+	resp := pq.Response()
+	if resp.Status == QuestionStatus_TimedOut && pq.Question.TimeoutBehavior == TimeoutBehavior_Error {
+		c.Status(fiber.StatusGatewayTimeout)
+	}
+	return c.JSON(resp)
+}
+
+// askSinks asks the question to all the sinks which support questions and
+// finishes it with the first answer received.
+func (s *HttpServer) askSinks(ctx context.Context, pq *PendingQuestion) {
+	if pq.Question.IsVote() {
+		s.collectVotes(ctx, pq)
+		return
+	}
 	resultsChan := make(chan sinkResult, len(s.Sinks))
 	var totalSinksAsked int
 	for _, sink := range s.Sinks {
-		if sinkWithQuestions, ok := sink.(NotificationSinkWithQuestions); ok {
+		if sinkWithQuestions, ok := sink.Sink.(NotificationSinkWithQuestions); ok {
 			totalSinksAsked++
-			go func() {
-				ans, err := sinkWithQuestions.AskQuestion(ctx, question)
+			go func(sinkID string, sink NotificationSinkWithQuestions) {
+				ans, err := sink.AskQuestion(ctx, pq.Question)
 				if err != nil {
-					resultsChan <- sinkResult{sinkName: fmt.Sprintf("%T", sink), err: err}
+					resultsChan <- sinkResult{sinkID: sinkID, err: err}
+					return
 				}
-				resultsChan <- sinkResult{sinkName: fmt.Sprintf("%T", sink), answer: ans}
-			}()
+				resultsChan <- sinkResult{sinkID: sinkID, answer: ans}
+			}(sink.ID, sinkWithQuestions)
 		}
 	}
 
 	errorsMap := make(map[string]string)
 	var answer *Answer
-	var i int
-	for result := range resultsChan {
-		i++
+	for i := 0; i < totalSinksAsked; i++ {
+		result := <-resultsChan
 		if result.err != nil {
-			errorsMap[result.sinkName] = result.err.Error()
+			errorsMap[result.sinkID] = result.err.Error()
 		} else {
 			answer = result.answer
-
+			if answer.AnsweredAt.IsZero() {
+				answer.AnsweredAt = time.Now()
+			}
 			break
 		}
-		if i == totalSinksAsked {
-			break
+	}
+
+	switch {
+	case answer == nil:
+		pq.finish(QuestionStatus_Failed, nil, errorsMap)
+	case answer.TimedOut:
+		pq.finish(QuestionStatus_TimedOut, answer, errorsMap)
+	default:
+		pq.finish(QuestionStatus_Answered, answer, errorsMap)
+	}
+}
+
+// collectVotes asks a question with multiple approvers to all the sinks
+// which support questions. Sinks implementing NotificationSinkWithVotes may
+// contribute many votes, the others contribute their single answer.
+func (s *HttpServer) collectVotes(ctx context.Context, pq *PendingQuestion) {
+	resultsChan := make(chan sinkResult, len(s.Sinks))
+	votesChan := make(chan sinkResult)
+	sinkIDs := []string{}
+	for _, sink := range s.Sinks {
+		sinkWithQuestions, ok := sink.Sink.(NotificationSinkWithQuestions)
+		if !ok {
+			continue
 		}
+		sinkID := sink.ID
+		sinkIDs = append(sinkIDs, sinkID)
+		go func(sink NotificationSinkWithQuestions) {
+			if sinkWithVotes, ok := sink.(NotificationSinkWithVotes); ok {
+				votes := make(chan *Answer)
+				go func() {
+					for vote := range votes {
+						select {
+						case votesChan <- sinkResult{sinkID: sinkID, answer: vote}:
+						case <-ctx.Done():
+						}
+					}
+				}()
+				err := sinkWithVotes.CollectVotes(ctx, pq.Question, votes)
+				close(votes)
+				resultsChan <- sinkResult{sinkID: sinkID, err: err}
+				return
+			}
+			ans, err := sink.AskQuestion(ctx, pq.Question)
+			resultsChan <- sinkResult{sinkID: sinkID, answer: ans, err: err}
+		}(sinkWithQuestions)
 	}
-	cancel()
 
-	return c.JSON(&PostQuestionResponse{
-		Errors: errorsMap,
-		Answer: answer,
-	})
+	tally := newVoteTally(pq.Question, sinkIDs)
+	errorsMap := make(map[string]string)
+	remaining := len(sinkIDs)
+	for remaining > 0 {
+		var result sinkResult
+		select {
+		case result = <-votesChan:
+		case result = <-resultsChan:
+			remaining--
+			if result.err != nil {
+				errorsMap[result.sinkID] = result.err.Error()
+				continue
+			}
+			if result.answer == nil || result.answer.TimedOut {
+				continue
+			}
+		case <-ctx.Done():
+			pq.finish(QuestionStatus_TimedOut, &Answer{
+				TimedOut:       true,
+				Value:          pq.Question.TimeoutValue(),
+				AnwserDuration: time.Since(pq.Question.Timestamp),
+				AnsweredAt:     time.Now(),
+				Votes:          tally.votes,
+			}, errorsMap)
+			return
+		}
+		if result.answer.AnsweredAt.IsZero() {
+			result.answer.AnsweredAt = time.Now()
+		}
+		if decided, approved := tally.add(result.sinkID, result.answer); decided {
+			pq.finish(QuestionStatus_Answered, &Answer{
+				Value:          approved,
+				AnwserDuration: time.Since(pq.Question.Timestamp),
+				AnsweredAt:     time.Now(),
+				Votes:          tally.votes,
+			}, errorsMap)
+			return
+		}
+	}
+	errorsMap["votes"] = tally.shortfall()
+	pq.finish(QuestionStatus_Failed, nil, errorsMap)
+}
+
+// userQuestion returns the question with the ID from the path if it was asked
+// by the logged in user, other users get a 404 as if it did not exist.
+func (s *HttpServer) userQuestion(c *fiber.Ctx) *PendingQuestion {
+	pq := s.Questions.Get(c.Params("id"))
+	user, _ := c.Context().UserValue("user").(*User)
+	if pq == nil || user == nil || pq.Username != user.Username {
+		return nil
+	}
+	return pq
+}
+
+// getQuestion godoc
+// @Summary Gets the status of a question
+// @Description Returns the status and answer (if any) of a question asked with POST /question by the same user
+// @ID get-question
+// @Param id path string true "Question ID"
+// @Produce  json
+// @Success 200 {object} PostQuestionResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 504 {object} PostQuestionResponse
+// @Router /question/{id} [get]
+// @Security ApiKeyAuth
+func (s *HttpServer) getQuestion(c *fiber.Ctx) error {
+	pq := s.userQuestion(c)
+	if pq == nil {
+		return c.Status(fiber.StatusNotFound).JSON(NewErrorResponse(fmt.Errorf("question not found")))
+	}
+	return s.sendQuestionResponse(c, pq)
+}
+
+// deleteQuestion godoc
+// @Summary Cancels a question
+// @Description Cancels a pending question asked by the same user. The sinks stop waiting for an answer.
+// @ID delete-question
+// @Param id path string true "Question ID"
+// @Produce  json
+// @Success 200 {object} PostQuestionResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /question/{id} [delete]
+// @Security ApiKeyAuth
+func (s *HttpServer) deleteQuestion(c *fiber.Ctx) error {
+	pq := s.userQuestion(c)
+	if pq == nil {
+		return c.Status(fiber.StatusNotFound).JSON(NewErrorResponse(fmt.Errorf("question not found")))
+	}
+	if !s.Questions.Cancel(pq.Question.ID) {
+		return c.Status(fiber.StatusConflict).JSON(NewErrorResponse(fmt.Errorf("question is not pending")))
+	}
+	return c.JSON(pq.Response())
 }
@@ -0,0 +1,191 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testQuestionSink answers questions with the values sent to answers, and
+// reports the context of every question it is asked.
+type testQuestionSink struct {
+	asked   chan context.Context
+	answers chan interface{}
+}
+
+func (sink *testQuestionSink) Init() error {
+	return nil
+}
+
+func (sink *testQuestionSink) DeliverNotification(notification *Notification) error {
+	return nil
+}
+
+func (sink *testQuestionSink) AskQuestion(ctx context.Context, question *Question) (*Answer, error) {
+	sink.asked <- ctx
+	select {
+	case value := <-sink.answers:
+		return &Answer{Value: value}, nil
+	case <-ctx.Done():
+		return &Answer{Value: question.TimeoutValue(), TimedOut: true}, nil
+	}
+}
+
+func (sink *testQuestionSink) nextAsked(t *testing.T) context.Context {
+	t.Helper()
+	select {
+	case ctx := <-sink.asked:
+		return ctx
+	case <-time.After(5 * time.Second):
+		t.Fatal("the question was not asked")
+		return nil
+	}
+}
+
+var testHttpUsers = []*User{
+	{Username: "alice", Token: "alice-token"},
+	{Username: "bob", Token: "bob-token"},
+}
+
+func newTestHttpServer(t *testing.T) (*HttpServer, *testQuestionSink) {
+	t.Helper()
+	sink := &testQuestionSink{
+		asked:   make(chan context.Context, 1),
+		answers: make(chan interface{}, 1),
+	}
+	s := NewHttpServer(
+		[]*configuredSink{{ID: "test", Sink: sink}},
+		testHttpUsers,
+		NewQuestionRegistry(time.Minute, nil),
+		nil,
+		nil,
+	)
+	s.registerRoutes()
+	return s, sink
+}
+
+// testQuestionResponse is the part of PostQuestionResponse checked by the
+// tests, Answer can't be unmarshaled since its durations are formatted.
+type testQuestionResponse struct {
+	ID     string         `json:"id"`
+	Status QuestionStatus `json:"status"`
+	Answer *struct {
+		Value interface{} `json:"value"`
+	} `json:"answer"`
+}
+
+func (s *HttpServer) testRequest(t *testing.T, method string, path string, token string, body interface{}) (int, *testQuestionResponse) {
+	t.Helper()
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &reqBody)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := s.router.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var resp testQuestionResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode, &resp
+}
+
+func TestPostQuestionSync(t *testing.T) {
+	s, sink := newTestHttpServer(t)
+	type result struct {
+		status int
+		resp   *testQuestionResponse
+	}
+	results := make(chan result, 1)
+	go func() {
+		status, resp := s.testRequest(t, http.MethodPost, "/question", "alice-token", PostQuestionBody{Text: "Deploy?"})
+		results <- result{status, resp}
+	}()
+	ctx := sink.nextAsked(t)
+	// the request context carries the values set by the middleware
+	if user, _ := ctx.Value("user").(*User); user == nil || user.Username != "alice" {
+		t.Error("the question was not asked with the request context")
+	}
+	sink.answers <- true
+	r := <-results
+	if r.status != http.StatusOK {
+		t.Fatalf("status = %v, want %v", r.status, http.StatusOK)
+	}
+	if r.resp.Status != QuestionStatus_Answered || r.resp.Answer == nil || r.resp.Answer.Value != true {
+		t.Errorf("response = %+v, want an answered question", r.resp)
+	}
+}
+
+func TestPostQuestionAsync(t *testing.T) {
+	s, sink := newTestHttpServer(t)
+	status, resp := s.testRequest(t, http.MethodPost, "/question", "alice-token", PostQuestionBody{Text: "Deploy?", Async: true})
+	if status != http.StatusOK || resp.Status != QuestionStatus_Pending || resp.ID == "" {
+		t.Fatalf("POST /question = %v %+v, want a pending question", status, resp)
+	}
+	ctx := sink.nextAsked(t)
+	if ctx.Value("user") != nil {
+		t.Error("an async question was asked with the request context")
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+		want       QuestionStatus
+	}{
+		{"pending", "alice-token", http.StatusOK, QuestionStatus_Pending},
+		// questions can only be polled by the user who asked them
+		{"other user", "bob-token", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, got := s.testRequest(t, http.MethodGet, "/question/"+resp.ID, tt.token, nil)
+			if status != tt.wantStatus || got.Status != tt.want {
+				t.Errorf("GET /question/{id} = %v %v, want %v %v", status, got.Status, tt.wantStatus, tt.want)
+			}
+		})
+	}
+
+	sink.answers <- true
+	<-s.Questions.Get(resp.ID).Done()
+	status, got := s.testRequest(t, http.MethodGet, "/question/"+resp.ID, "alice-token", nil)
+	if status != http.StatusOK || got.Status != QuestionStatus_Answered || got.Answer.Value != true {
+		t.Errorf("GET /question/{id} = %v %+v, want an answered question", status, got)
+	}
+	if status, _ := s.testRequest(t, http.MethodGet, "/question/unknown", "alice-token", nil); status != http.StatusNotFound {
+		t.Errorf("GET /question/unknown = %v, want %v", status, http.StatusNotFound)
+	}
+}
+
+func TestDeleteQuestion(t *testing.T) {
+	s, sink := newTestHttpServer(t)
+	_, resp := s.testRequest(t, http.MethodPost, "/question", "alice-token", PostQuestionBody{Text: "Deploy?", Async: true})
+	ctx := sink.nextAsked(t)
+
+	if status, _ := s.testRequest(t, http.MethodDelete, "/question/"+resp.ID, "bob-token", nil); status != http.StatusNotFound {
+		t.Errorf("DELETE by another user = %v, want %v", status, http.StatusNotFound)
+	}
+	status, got := s.testRequest(t, http.MethodDelete, "/question/"+resp.ID, "alice-token", nil)
+	if status != http.StatusOK || got.Status != QuestionStatus_Cancelled {
+		t.Errorf("DELETE /question/{id} = %v %v, want %v %v", status, got.Status, http.StatusOK, QuestionStatus_Cancelled)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Error("the sinks were not told to stop waiting for an answer")
+	}
+	if status, _ := s.testRequest(t, http.MethodDelete, "/question/"+resp.ID, "alice-token", nil); status != http.StatusConflict {
+		t.Errorf("second DELETE = %v, want %v", status, http.StatusConflict)
+	}
+}
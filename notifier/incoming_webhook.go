@@ -0,0 +1,65 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// incomingWebhook posts JSON payloads to the incoming webhook of a chat
+// service. It is embedded by the sinks which only differ in the payload they
+// send, so that such a sink only has to render its payload.
+type incomingWebhook struct {
+	WebhookURL string
+	client     *http.Client
+}
+
+func (w *incomingWebhook) init() error {
+	if w.WebhookURL == "" {
+		return fmt.Errorf("webhook_url is not set")
+	}
+	w.client = &http.Client{
+		Timeout: 10 * time.Second,
+	}
+	return nil
+}
+
+func (w *incomingWebhook) post(payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, w.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Notifier")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook responded with status %v: %v", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// severityColor returns the color of the accent of the message for the severity.
+func severityColor(severity Severity) string {
+	switch severity {
+	case Severity_Emergency, Severity_Alert, Severity_Critical, Severity_Error:
+		return "#d32f2f"
+	case Severity_Warning:
+		return "#f9a825"
+	case Severity_Debug:
+		return "#9e9e9e"
+	}
+	return "#1976d2"
+}
@@ -0,0 +1,95 @@
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestIncomingWebhook returns a server answering with status and the
+// channel the payloads posted to it are sent to.
+func newTestIncomingWebhook(t *testing.T, status int) (*httptest.Server, <-chan map[string]interface{}) {
+	payloads := make(chan map[string]interface{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("got a %v request with content type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		var payload map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+		payloads <- payload
+		w.WriteHeader(status)
+		w.Write([]byte("invalid payload\n"))
+	}))
+	t.Cleanup(server.Close)
+	return server, payloads
+}
+
+// firstAttachment returns the first element of the attachments of a payload.
+func firstAttachment(t *testing.T, payload map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	attachments, _ := payload["attachments"].([]interface{})
+	if len(attachments) == 0 {
+		t.Fatalf("payload %v has no attachments", payload)
+	}
+	attachment, _ := attachments[0].(map[string]interface{})
+	return attachment
+}
+
+func TestIncomingWebhookPost(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr string
+	}{
+		{"ok", http.StatusOK, ""},
+		{"no content", http.StatusNoContent, ""},
+		{"bad request", http.StatusBadRequest, "webhook responded with status 400: invalid payload"},
+		{"not modified", http.StatusNotModified, "webhook responded with status 304: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, payloads := newTestIncomingWebhook(t, tt.status)
+			w := &incomingWebhook{WebhookURL: server.URL}
+			if err := w.init(); err != nil {
+				t.Fatal(err)
+			}
+			err := w.post(map[string]string{"text": "hello"})
+			if payload := <-payloads; payload["text"] != "hello" {
+				t.Errorf("payload = %v", payload)
+			}
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("post() = %v, want no error", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("post() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIncomingWebhookRequiresURL(t *testing.T) {
+	if err := (&incomingWebhook{}).init(); err == nil {
+		t.Error("init() accepted an empty webhook_url")
+	}
+}
+
+func TestSeverityColor(t *testing.T) {
+	tests := []struct {
+		severity Severity
+		want     string
+	}{
+		{Severity_Critical, "#d32f2f"},
+		{Severity_Warning, "#f9a825"},
+		{Severity_Debug, "#9e9e9e"},
+		{Severity_Info, "#1976d2"},
+		{"", "#1976d2"},
+	}
+	for _, tt := range tests {
+		if got := severityColor(tt.severity); got != tt.want {
+			t.Errorf("severityColor(%q) = %v, want %v", tt.severity, got, tt.want)
+		}
+	}
+}
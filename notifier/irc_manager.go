@@ -0,0 +1,329 @@
+package notifier
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	irc "github.com/thoj/go-ircevent"
+)
+
+// IRCCredentials identify a connection to an IRC server. Sinks with the same
+// credentials share the connection.
+type IRCCredentials struct {
+	Server       string // host:port
+	TLS          bool
+	Nick         string
+	Password     string // server password
+	SASLPassword string // authenticates the nick with SASL PLAIN when set
+}
+
+// ircMessage is a PRIVMSG received by the connection.
+type ircMessage struct {
+	Target string // the channel, or our nick for private messages
+	Nick   string
+	Text   string
+	Tags   map[string]string
+}
+
+type ircMessageListener struct {
+	handler func(msg *ircMessage)
+	ID      int64
+}
+
+type ircWhoxQuery struct {
+	nick   string
+	result chan string
+}
+
+// IRCConnection is a connection to an IRC server, which reconnects and
+// rejoins its channels automatically.
+type IRCConnection struct {
+	conn        *irc.Connection
+	mutex       sync.RWMutex
+	registered  bool // the channels are joined once the server welcomes the client
+	channels    map[string]bool
+	listeners   []*ircMessageListener
+	accountTag  bool // the server tags the messages with the services account of the sender
+	whox        bool // the server can look up the services account of a nick with WHO
+	whoxQueries map[string]*ircWhoxQuery
+	motdDone    chan struct{}
+	capDone     chan struct{}
+	readyOnce   sync.Once
+	capOnce     sync.Once
+}
+
+// IRCManager shares the IRC connections between the sinks.
+type IRCManager struct {
+	mutex       sync.Mutex
+	connections map[IRCCredentials]*IRCConnection
+}
+
+func NewIRCManager() *IRCManager {
+	return &IRCManager{
+		connections: make(map[IRCCredentials]*IRCConnection),
+	}
+}
+
+func (m *IRCManager) RegisterConnection(credentials IRCCredentials) (*IRCConnection, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if c, ok := m.connections[credentials]; ok {
+		return c, nil
+	}
+
+	c := &IRCConnection{
+		channels:    make(map[string]bool),
+		whoxQueries: make(map[string]*ircWhoxQuery),
+		motdDone:    make(chan struct{}),
+		capDone:     make(chan struct{}),
+	}
+	conn := irc.IRC(credentials.Nick, credentials.Nick)
+	conn.UseTLS = credentials.TLS
+	if credentials.TLS {
+		conn.TLSConfig = &tls.Config{ServerName: strings.Split(credentials.Server, ":")[0]}
+	}
+	conn.Password = credentials.Password
+	if credentials.SASLPassword != "" {
+		conn.UseSASL = true
+		conn.SASLLogin = credentials.Nick
+		conn.SASLPassword = credentials.SASLPassword
+		conn.SASLMech = "PLAIN"
+	}
+	conn.Timeout = 30 * time.Second
+	conn.AddCallback("001", func(e *irc.Event) {
+		// joins again after reconnecting
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		c.registered = true
+		// learned again, the server may have changed
+		c.accountTag = false
+		c.whox = false
+		for channel := range c.channels {
+			e.Connection.Join(channel)
+		}
+		e.Connection.SendRaw("CAP REQ :account-tag")
+	})
+	conn.AddCallback("CAP", func(e *irc.Event) {
+		if len(e.Arguments) < 3 || (e.Arguments[1] != "ACK" && e.Arguments[1] != "NAK") {
+			return
+		}
+		for _, capability := range strings.Fields(e.Arguments[2]) {
+			if capability == "account-tag" {
+				c.mutex.Lock()
+				c.accountTag = e.Arguments[1] == "ACK"
+				c.mutex.Unlock()
+				c.capOnce.Do(func() { close(c.capDone) })
+			}
+		}
+	})
+	conn.AddCallback("421", func(e *irc.Event) {
+		// servers without capability negotiation reject CAP as an unknown command
+		if len(e.Arguments) > 1 && e.Arguments[1] == "CAP" {
+			c.capOnce.Do(func() { close(c.capDone) })
+		}
+	})
+	conn.AddCallback("005", func(e *irc.Event) {
+		for _, token := range e.Arguments {
+			if token == "WHOX" {
+				c.mutex.Lock()
+				c.whox = true
+				c.mutex.Unlock()
+			}
+		}
+	})
+	endOfMotd := func(e *irc.Event) {
+		c.readyOnce.Do(func() { close(c.motdDone) })
+	}
+	conn.AddCallback("376", endOfMotd)
+	conn.AddCallback("422", endOfMotd) // no MOTD
+	conn.AddCallback("354", func(e *irc.Event) {
+		// reply to WHO <nick> %tna,<token>: token, nick, account ("0" when logged out)
+		if len(e.Arguments) < 4 {
+			return
+		}
+		c.finishWhox(e.Arguments[1], e.Arguments[3])
+	})
+	conn.AddCallback("315", func(e *irc.Event) {
+		// end of WHO, the nick is not on the server when no reply came before it
+		if len(e.Arguments) < 2 {
+			return
+		}
+		c.mutex.RLock()
+		tokens := []string{}
+		for token, query := range c.whoxQueries {
+			if strings.EqualFold(query.nick, e.Arguments[1]) {
+				tokens = append(tokens, token)
+			}
+		}
+		c.mutex.RUnlock()
+		for _, token := range tokens {
+			c.finishWhox(token, "0")
+		}
+	})
+	conn.AddCallback("PRIVMSG", func(e *irc.Event) {
+		if len(e.Arguments) == 0 {
+			return
+		}
+		// the callbacks block reading from the server, the listeners may have to wait for WHO replies
+		go c.dispatch(&ircMessage{
+			Target: e.Arguments[0],
+			Nick:   e.Nick,
+			Text:   e.Message(),
+			Tags:   e.Tags,
+		})
+	})
+	if err := conn.Connect(credentials.Server); err != nil {
+		return nil, fmt.Errorf("failed to connect to %v: %w", credentials.Server, err)
+	}
+	c.conn = conn
+	go conn.Loop()
+
+	m.connections[credentials] = c
+	return c, nil
+}
+
+// Join joins the channel now and after every reconnection. Targets which are
+// not channels are ignored.
+func (c *IRCConnection) Join(channel string) {
+	if !strings.HasPrefix(channel, "#") && !strings.HasPrefix(channel, "&") {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.channels[channel] {
+		return
+	}
+	c.channels[channel] = true
+	if c.registered {
+		c.conn.Join(channel)
+	}
+}
+
+// Send sends the text to a channel or a nick, one message per line.
+func (c *IRCConnection) Send(target string, text string) error {
+	if !c.conn.Connected() {
+		return fmt.Errorf("not connected to %v", c.conn.Server)
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		c.conn.Privmsg(target, line)
+	}
+	return nil
+}
+
+// WaitReady waits until the server has sent its welcome messages and answered
+// the capability request, so that SupportsAccounts is known.
+func (c *IRCConnection) WaitReady(timeout time.Duration) error {
+	select {
+	case <-c.motdDone:
+	case <-time.After(timeout):
+		return fmt.Errorf("timed out waiting for %v to welcome the client", c.conn.Server)
+	}
+	select {
+	case <-c.capDone:
+	case <-time.After(5 * time.Second):
+	}
+	return nil
+}
+
+// SupportsAccounts reports whether the services account of the senders can be
+// looked up, which unlike nicks can't be taken by anyone.
+func (c *IRCConnection) SupportsAccounts() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.accountTag || c.whox
+}
+
+// Account returns the services account the sender of the message is logged
+// in with, or "" when it is not logged in.
+func (c *IRCConnection) Account(msg *ircMessage) (string, error) {
+	c.mutex.RLock()
+	accountTag, whox := c.accountTag, c.whox
+	c.mutex.RUnlock()
+	if account, ok := msg.Tags["account"]; ok {
+		return account, nil
+	}
+	if accountTag {
+		// the tag is left out for senders which are not logged in
+		return "", nil
+	}
+	if !whox {
+		return "", fmt.Errorf("%v supports neither account-tag nor WHOX", c.conn.Server)
+	}
+	token := strconv.Itoa(100 + rand.Intn(900))
+	query := &ircWhoxQuery{nick: msg.Nick, result: make(chan string, 1)}
+	c.mutex.Lock()
+	c.whoxQueries[token] = query
+	c.mutex.Unlock()
+	defer func() {
+		c.mutex.Lock()
+		delete(c.whoxQueries, token)
+		c.mutex.Unlock()
+	}()
+	c.conn.SendRawf("WHO %v %%tna,%v", msg.Nick, token)
+	select {
+	case account := <-query.result:
+		if account == "0" {
+			return "", nil
+		}
+		return account, nil
+	case <-time.After(10 * time.Second):
+		return "", fmt.Errorf("timed out looking up the account of %v", msg.Nick)
+	}
+}
+
+func (c *IRCConnection) finishWhox(token string, account string) {
+	c.mutex.RLock()
+	query, ok := c.whoxQueries[token]
+	c.mutex.RUnlock()
+	if !ok {
+		return
+	}
+	select {
+	case query.result <- account:
+	default:
+	}
+}
+
+func (c *IRCConnection) AddMessageListener(handler func(msg *ircMessage)) func() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	added := &ircMessageListener{
+		handler: handler,
+		ID:      rand.Int63(),
+	}
+	c.listeners = append(c.listeners, added)
+	return func() {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		for i, l := range c.listeners {
+			if l.ID == added.ID {
+				c.listeners = append(c.listeners[:i], c.listeners[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (c *IRCConnection) dispatch(msg *ircMessage) {
+	c.mutex.RLock()
+	listeners := append([]*ircMessageListener{}, c.listeners...)
+	c.mutex.RUnlock()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic in irc message handler: %v", r)
+		}
+	}()
+	for _, l := range listeners {
+		l.handler(msg)
+	}
+}
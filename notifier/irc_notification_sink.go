@@ -0,0 +1,198 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// IRCNotificationSink sends notifications to IRC channels or nicks. The
+// connection is shared with the other IRC sinks using the same server and
+// nick. IRC has no replies, so questions are answered by sending a message
+// starting with the short code included in the prompt, like "K7QX yes".
+type IRCNotificationSink struct {
+	Server       string // host:port
+	TLS          bool
+	Nick         string
+	Password     string
+	SASLPassword string
+	Targets      []string // channels (#channel) or nicks
+	AllowedUsers []string // services accounts, anyone in the conversation can answer when empty
+	IRCManager   *IRCManager
+	connection   *IRCConnection
+}
+
+func (sink *IRCNotificationSink) Init() error {
+	if sink.Server == "" || sink.Nick == "" || len(sink.Targets) == 0 {
+		return fmt.Errorf("server, nick and targets must be set")
+	}
+	connection, err := sink.IRCManager.RegisterConnection(IRCCredentials{
+		Server:       sink.Server,
+		TLS:          sink.TLS,
+		Nick:         sink.Nick,
+		Password:     sink.Password,
+		SASLPassword: sink.SASLPassword,
+	})
+	if err != nil {
+		return err
+	}
+	sink.connection = connection
+	for _, target := range sink.Targets {
+		connection.Join(target)
+	}
+	if len(sink.AllowedUsers) > 0 {
+		// nicks can be taken by anyone, so the allowlist needs the services accounts
+		if err := connection.WaitReady(30 * time.Second); err != nil {
+			return err
+		}
+		if !connection.SupportsAccounts() {
+			return fmt.Errorf("allowed_users needs a server supporting the account-tag capability or WHOX to look up the accounts of the senders")
+		}
+	}
+	log.Printf("Successfully initialized %T", *sink)
+	return nil
+}
+
+func (sink *IRCNotificationSink) DeliverNotification(notification *Notification) error {
+	message := notification.Body
+	if notification.Title != "" {
+		message = notification.Title + "\n" + message
+	}
+	return sink.send(message)
+}
+
+func (sink *IRCNotificationSink) send(message string) error {
+	for _, target := range sink.Targets {
+		if err := sink.connection.Send(target, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sink *IRCNotificationSink) AskQuestion(ctx context.Context, question *Question) (*Answer, error) {
+	code := generateShortCode()
+	prompt, err := codedQuestionPrompt(question, code)
+	if err != nil {
+		return nil, err
+	}
+
+	questionAskedTime := time.Now()
+	answerChan := make(chan *Answer, 1)
+	removeListener := sink.connection.AddMessageListener(func(msg *ircMessage) {
+		nick := msg.Nick
+		replyTo, ok := sink.conversation(msg.Target, nick)
+		if !ok {
+			return
+		}
+		answerText, ok := parseCodedAnswer(code, msg.Text)
+		if !ok {
+			return
+		}
+		value, label, ok := parseTextAnswer(question, answerText)
+		if !ok {
+			sink.reply(replyTo, fmt.Sprintf("Unrecognized answer for [%v]", code))
+			return
+		}
+		id := nick
+		if len(sink.AllowedUsers) > 0 {
+			account, err := sink.connection.Account(msg)
+			if err != nil {
+				log.Printf("failed to look up the irc account of %v: %v", nick, err)
+			}
+			if err != nil || !sink.isAllowedToAnswer(account) {
+				sink.reply(replyTo, fmt.Sprintf("%v: you are not allowed to answer this question", nick))
+				return
+			}
+			id = account
+		}
+		select {
+		case answerChan <- &Answer{
+			Value:          value,
+			AnwserDuration: time.Since(questionAskedTime),
+			AnsweredBy: &Answerer{
+				Sink:        "irc",
+				ID:          id,
+				Username:    id,
+				DisplayName: nick,
+			},
+			AnsweredAt: time.Now(),
+		}:
+			sink.reply(replyTo, fmt.Sprintf("[%v] Answered: %v (by %v)", code, label, nick))
+		default:
+		}
+	})
+	defer removeListener()
+
+	if err := sink.send(prompt); err != nil {
+		return nil, fmt.Errorf("failed to send question: %w", err)
+	}
+
+	select {
+	case answer := <-answerChan:
+		return answer, nil
+	case <-ctx.Done():
+		if err := sink.send(fmt.Sprintf("[%v] %v", code, question.TimeoutLabel())); err != nil {
+			log.Printf("failed to send irc message: %v", err)
+		}
+		return &Answer{
+			Value:          question.TimeoutValue(),
+			TimedOut:       true,
+			AnwserDuration: time.Since(questionAskedTime),
+		}, nil
+	}
+}
+
+// conversation returns where to reply to a message, if it was sent in one of
+// the channels of the sink or privately by one of its target nicks.
+func (sink *IRCNotificationSink) conversation(target string, nick string) (string, bool) {
+	for _, t := range sink.Targets {
+		if strings.EqualFold(t, target) {
+			return t, true
+		}
+		if strings.EqualFold(target, sink.Nick) && strings.EqualFold(t, nick) {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+func (sink *IRCNotificationSink) reply(target string, message string) {
+	if err := sink.connection.Send(target, message); err != nil {
+		log.Printf("failed to send irc message: %v", err)
+	}
+}
+
+// isAllowedToAnswer checks the services account of the sender, which is ""
+// when it is not logged in.
+func (sink *IRCNotificationSink) isAllowedToAnswer(account string) bool {
+	if len(sink.AllowedUsers) == 0 {
+		return true
+	}
+	if account == "" {
+		return false
+	}
+	for _, allowed := range sink.AllowedUsers {
+		if strings.EqualFold(allowed, account) {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	registerSinkType("irc", func(config sinkConfig, managers *sinkManagers) (NotificationSink, error) {
+		return &IRCNotificationSink{
+			Server:       config.String("server"),
+			TLS:          config.Bool("tls"),
+			Nick:         config.String("nick"),
+			Password:     config.String("password"),
+			SASLPassword: config.String("sasl_password"),
+			Targets:      config.Strings("targets"),
+			AllowedUsers: config.Strings("allowed_users"),
+			IRCManager:   managers.IRC,
+		}, nil
+	})
+}
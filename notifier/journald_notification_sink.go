@@ -0,0 +1,120 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const journaldDefaultSocketPath = "/run/systemd/journal/socket"
+
+// JournaldNotificationSink writes notifications to the systemd journal with
+// its native protocol. The title and timestamp of the notification are
+// stored in the NOTIFIER_TITLE and NOTIFIER_TIMESTAMP fields.
+type JournaldNotificationSink struct {
+	SocketPath string
+	Identifier string
+	Severity   Severity // used for notifications without a severity
+	mutex      sync.Mutex
+	conn       *net.UnixConn
+}
+
+func (sink *JournaldNotificationSink) Init() error {
+	if sink.SocketPath == "" {
+		sink.SocketPath = journaldDefaultSocketPath
+	}
+	if sink.Identifier == "" {
+		sink.Identifier = "notifier"
+	}
+	if sink.Severity == "" {
+		sink.Severity = Severity_Notice
+	}
+	if _, err := sink.Severity.SyslogLevel(); err != nil {
+		return err
+	}
+	if err := sink.connect(); err != nil {
+		return err
+	}
+	log.Printf("Successfully initialized %T", sink)
+	return nil
+}
+
+func (sink *JournaldNotificationSink) connect() error {
+	if sink.conn != nil {
+		sink.conn.Close()
+		sink.conn = nil
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: sink.SocketPath, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to connect to the journal: %w", err)
+	}
+	sink.conn = conn
+	return nil
+}
+
+func (sink *JournaldNotificationSink) DeliverNotification(notification *Notification) error {
+	severity := notification.Severity
+	if severity == "" {
+		severity = sink.Severity
+	}
+	level, err := severity.SyslogLevel()
+	if err != nil {
+		return err
+	}
+	message := notification.Body
+	if notification.Title != "" {
+		message = notification.Title + ": " + message
+	}
+	var entry bytes.Buffer
+	writeJournalField(&entry, "MESSAGE", message)
+	writeJournalField(&entry, "PRIORITY", strconv.Itoa(level))
+	writeJournalField(&entry, "SYSLOG_IDENTIFIER", sink.Identifier)
+	writeJournalField(&entry, "NOTIFIER_TITLE", notification.Title)
+	writeJournalField(&entry, "NOTIFIER_BODY", notification.Body)
+	writeJournalField(&entry, "NOTIFIER_TIMESTAMP", notification.Timestamp.Format(time.RFC3339Nano))
+
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if sink.conn == nil {
+		if err := sink.connect(); err != nil {
+			return err
+		}
+	}
+	if _, err := sink.conn.Write(entry.Bytes()); err != nil {
+		// the socket is recreated when journald restarts, retry once with a new connection
+		if err := sink.connect(); err != nil {
+			return err
+		}
+		_, err = sink.conn.Write(entry.Bytes())
+		return err
+	}
+	return nil
+}
+
+// writeJournalField appends a field in the format of the native journal
+// protocol. Values containing newlines are written with their length.
+func writeJournalField(w *bytes.Buffer, name string, value string) {
+	if !strings.Contains(value, "\n") {
+		w.WriteString(name + "=" + value + "\n")
+		return
+	}
+	w.WriteString(name + "\n")
+	binary.Write(w, binary.LittleEndian, uint64(len(value)))
+	w.WriteString(value + "\n")
+}
+
+func init() {
+	registerSinkType("journald", func(config sinkConfig, managers *sinkManagers) (NotificationSink, error) {
+		return &JournaldNotificationSink{
+			SocketPath: config.String("socket_path"),
+			Identifier: config.String("identifier"),
+			Severity:   Severity(strings.ToLower(config.String("severity"))),
+		}, nil
+	})
+}
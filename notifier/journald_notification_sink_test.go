@@ -0,0 +1,115 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// listenTestUnixgram listens on a datagram socket, like journald and syslog do.
+func listenTestUnixgram(t *testing.T, path string) *net.UnixConn {
+	t.Helper()
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readTestDatagram(t *testing.T, conn *net.UnixConn) []byte {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 65536)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("nothing was received: %v", err)
+	}
+	return buf[:n]
+}
+
+// parseJournalEntry decodes an entry in the native journal protocol.
+func parseJournalEntry(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	fields := make(map[string]string)
+	for len(data) > 0 {
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line = data[:i]
+			data = data[i+1:]
+		} else {
+			data = nil
+		}
+		if i := bytes.IndexByte(line, '='); i >= 0 {
+			fields[string(line[:i])] = string(line[i+1:])
+			continue
+		}
+		if len(data) < 8 {
+			t.Fatalf("field %s has no length", line)
+		}
+		size := binary.LittleEndian.Uint64(data[:8])
+		if uint64(len(data)) < 8+size+1 {
+			t.Fatalf("field %s is shorter than %v bytes", line, size)
+		}
+		fields[string(line)] = string(data[8 : 8+size])
+		data = data[8+size+1:]
+	}
+	return fields
+}
+
+func TestJournaldNotificationSinkDeliverNotification(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.sock")
+	journal := listenTestUnixgram(t, path)
+	sink := &JournaldNotificationSink{SocketPath: path, Identifier: "backup"}
+	if err := sink.Init(); err != nil {
+		t.Fatal(err)
+	}
+	err := sink.DeliverNotification(&Notification{
+		Title:     "Backup failed",
+		Body:      "disk full\non /var",
+		Severity:  Severity_Error,
+		Timestamp: time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := parseJournalEntry(t, readTestDatagram(t, journal))
+	want := map[string]string{
+		"MESSAGE":            "Backup failed: disk full\non /var",
+		"PRIORITY":           "3",
+		"SYSLOG_IDENTIFIER":  "backup",
+		"NOTIFIER_TITLE":     "Backup failed",
+		"NOTIFIER_BODY":      "disk full\non /var",
+		"NOTIFIER_TIMESTAMP": "2021-05-01T12:00:00Z",
+	}
+	for name, value := range want {
+		if fields[name] != value {
+			t.Errorf("%v = %q, want %q", name, fields[name], value)
+		}
+	}
+}
+
+func TestJournaldNotificationSinkReconnect(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.sock")
+	journal := listenTestUnixgram(t, path)
+	sink := &JournaldNotificationSink{SocketPath: path}
+	if err := sink.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	// journald restarts and creates a new socket
+	journal.Close()
+	os.Remove(path)
+	journal = listenTestUnixgram(t, path)
+
+	if err := sink.DeliverNotification(&Notification{Title: "Backup", Body: "finished"}); err != nil {
+		t.Fatal(err)
+	}
+	if fields := parseJournalEntry(t, readTestDatagram(t, journal)); fields["MESSAGE"] != "Backup: finished" {
+		t.Errorf("MESSAGE = %q, want %q", fields["MESSAGE"], "Backup: finished")
+	}
+}
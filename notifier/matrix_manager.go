@@ -0,0 +1,228 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type matrixInReplyTo struct {
+	EventID string `json:"event_id"`
+}
+
+type matrixRelatesTo struct {
+	RelType   string           `json:"rel_type,omitempty"`
+	EventID   string           `json:"event_id,omitempty"`
+	Key       string           `json:"key,omitempty"`
+	InReplyTo *matrixInReplyTo `json:"m.in_reply_to,omitempty"`
+}
+
+type matrixEventContent struct {
+	MsgType       string           `json:"msgtype,omitempty"`
+	Body          string           `json:"body,omitempty"`
+	Format        string           `json:"format,omitempty"`
+	FormattedBody string           `json:"formatted_body,omitempty"`
+	RelatesTo     *matrixRelatesTo `json:"m.relates_to,omitempty"`
+}
+
+type matrixEvent struct {
+	Type    string             `json:"type"`
+	EventID string             `json:"event_id"`
+	Sender  string             `json:"sender"`
+	Content matrixEventContent `json:"content"`
+}
+
+type matrixSyncResponse struct {
+	NextBatch string `json:"next_batch"`
+	Rooms     struct {
+		Join map[string]struct {
+			Timeline struct {
+				Events []*matrixEvent `json:"events"`
+			} `json:"timeline"`
+		} `json:"join"`
+	} `json:"rooms"`
+}
+
+// MatrixClient talks to a homeserver with the client-server API on behalf of one user.
+type MatrixClient struct {
+	Homeserver  string
+	AccessToken string
+	UserID      string
+	client      *http.Client
+}
+
+func (c *MatrixClient) do(method string, path string, query url.Values, payload interface{}, result interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	u := strings.TrimSuffix(c.Homeserver, "/") + "/_matrix/client/r0" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.AccessToken)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("homeserver responded with status %v: %v", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	if result != nil {
+		return json.NewDecoder(resp.Body).Decode(result)
+	}
+	return nil
+}
+
+// SendEvent sends a message event to the room and returns its ID.
+func (c *MatrixClient) SendEvent(roomID string, eventType string, content *matrixEventContent) (string, error) {
+	var resp struct {
+		EventID string `json:"event_id"`
+	}
+	txnID := fmt.Sprintf("notifier%v%x", time.Now().UnixNano(), rand.Int63())
+	path := fmt.Sprintf("/rooms/%v/send/%v/%v", url.PathEscape(roomID), url.PathEscape(eventType), txnID)
+	if err := c.do(http.MethodPut, path, nil, content, &resp); err != nil {
+		return "", err
+	}
+	return resp.EventID, nil
+}
+
+type matrixEventListener struct {
+	handler func(roomID string, event *matrixEvent)
+	ID      int64
+}
+
+// MatrixManager shares one sync loop per homeserver and access token between
+// all the Matrix sinks using them, and dispatches the received events.
+type MatrixManager struct {
+	clients        map[string]*MatrixClient
+	clientsMutex   sync.RWMutex
+	listenersMutex sync.RWMutex
+	eventListeners map[string][]*matrixEventListener
+}
+
+func NewMatrixManager() *MatrixManager {
+	return &MatrixManager{
+		clients:        make(map[string]*MatrixClient),
+		eventListeners: make(map[string][]*matrixEventListener),
+	}
+}
+
+func matrixClientKey(homeserver string, accessToken string) string {
+	return strings.TrimSuffix(homeserver, "/") + "|" + accessToken
+}
+
+func (m *MatrixManager) RegisterClient(homeserver string, accessToken string) (*MatrixClient, error) {
+	m.clientsMutex.Lock()
+	defer m.clientsMutex.Unlock()
+
+	key := matrixClientKey(homeserver, accessToken)
+	if client, ok := m.clients[key]; ok {
+		return client, nil
+	}
+
+	client := &MatrixClient{
+		Homeserver:  homeserver,
+		AccessToken: accessToken,
+		client: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+	}
+	var whoami struct {
+		UserID string `json:"user_id"`
+	}
+	if err := client.do(http.MethodGet, "/account/whoami", nil, nil, &whoami); err != nil {
+		return nil, fmt.Errorf("failed to log in to %v: %w", homeserver, err)
+	}
+	client.UserID = whoami.UserID
+
+	// the first sync only fetches the position in the timeline, so old events are not dispatched
+	var initial matrixSyncResponse
+	if err := client.do(http.MethodGet, "/sync", url.Values{"timeout": {"0"}, "filter": {`{"room":{"timeline":{"limit":1}}}`}}, nil, &initial); err != nil {
+		return nil, fmt.Errorf("failed to sync with %v: %w", homeserver, err)
+	}
+
+	m.clients[key] = client
+
+	go m.runSyncLoop(key, client, initial.NextBatch)
+
+	return client, nil
+}
+
+func (m *MatrixManager) AddEventListener(homeserver string, accessToken string, listener func(roomID string, event *matrixEvent)) func() {
+	key := matrixClientKey(homeserver, accessToken)
+	m.listenersMutex.Lock()
+	defer m.listenersMutex.Unlock()
+	added := &matrixEventListener{
+		handler: listener,
+		ID:      rand.Int63(),
+	}
+	m.eventListeners[key] = append(m.eventListeners[key], added)
+	return func() {
+		m.listenersMutex.Lock()
+		defer m.listenersMutex.Unlock()
+		for i, l := range m.eventListeners[key] {
+			if l.ID == added.ID {
+				m.eventListeners[key] = append(m.eventListeners[key][:i], m.eventListeners[key][i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (m *MatrixManager) runSyncLoop(key string, client *MatrixClient, since string) {
+	for {
+		var resp matrixSyncResponse
+		err := client.do(http.MethodGet, "/sync", url.Values{"since": {since}, "timeout": {"30000"}}, nil, &resp)
+		if err != nil {
+			log.Printf("Matrix sync with %v failed, retrying: %v", client.Homeserver, err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		since = resp.NextBatch
+
+		for roomID, room := range resp.Rooms.Join {
+			for _, event := range room.Timeline.Events {
+				if event.Sender == client.UserID {
+					continue
+				}
+				m.dispatchEvent(key, roomID, event)
+			}
+		}
+	}
+}
+
+func (m *MatrixManager) dispatchEvent(key string, roomID string, event *matrixEvent) {
+	m.listenersMutex.RLock()
+	listeners := append([]*matrixEventListener{}, m.eventListeners[key]...)
+	m.listenersMutex.RUnlock()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic in matrix event handler: %v", r)
+		}
+	}()
+	for _, l := range listeners {
+		l.handler(roomID, event)
+	}
+}
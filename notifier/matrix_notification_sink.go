@@ -0,0 +1,231 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+)
+
+// MatrixNotificationSink sends notifications as formatted messages to a
+// Matrix room. Questions are answered by reacting to the question with ✅ or
+// ❌, or by replying to it.
+type MatrixNotificationSink struct {
+	Homeserver    string
+	AccessToken   string
+	RoomID        string
+	AllowedUsers  []string // full Matrix user IDs (@user:server), anyone in the room can answer when empty
+	MatrixManager *MatrixManager
+	QuestionStore *QuestionStore
+	client        *MatrixClient
+}
+
+func (sink *MatrixNotificationSink) Init() error {
+	if sink.Homeserver == "" || sink.AccessToken == "" || sink.RoomID == "" {
+		return fmt.Errorf("homeserver, access_token and room_id must be set")
+	}
+	for _, allowed := range sink.AllowedUsers {
+		// a localpart alone would match the same name on any federated homeserver
+		if !strings.HasPrefix(allowed, "@") || !strings.Contains(allowed, ":") {
+			return fmt.Errorf("allowed_users must be full user IDs like @user:example.com, got %q", allowed)
+		}
+	}
+	client, err := sink.MatrixManager.RegisterClient(sink.Homeserver, sink.AccessToken)
+	if err != nil {
+		return err
+	}
+	sink.client = client
+	log.Printf("Successfully initialized %T", *sink)
+	return nil
+}
+
+func (sink *MatrixNotificationSink) DeliverNotification(notification *Notification) error {
+	body := notification.Body
+	formattedBody := matrixHTML(notification.Body)
+	if notification.Title != "" {
+		body = notification.Title + "\n" + body
+		formattedBody = "<strong>" + html.EscapeString(notification.Title) + "</strong><br>" + formattedBody
+	}
+	date := formatDate(notification.Timestamp)
+	_, err := sink.client.SendEvent(sink.RoomID, "m.room.message", &matrixEventContent{
+		MsgType:       "m.text",
+		Body:          body + "\n" + date,
+		Format:        "org.matrix.custom.html",
+		FormattedBody: formattedBody + "<br><sub>" + html.EscapeString(date) + "</sub>",
+	})
+	return err
+}
+
+func (sink *MatrixNotificationSink) AskQuestion(ctx context.Context, question *Question) (*Answer, error) {
+	var hint string
+	switch question.Kind {
+	case QuestionKind_YesNo:
+		hint = "React with ✅ for yes or ❌ for no, or reply with yes or no."
+	case QuestionKind_Choice:
+		options := []string{}
+		for i, option := range question.Options {
+			options = append(options, fmt.Sprintf("%v. %v", i+1, option.Label))
+		}
+		hint = strings.Join(options, "\n") + "\nReply with the number of an option."
+	case QuestionKind_Text:
+		hint = "Reply to this message to answer."
+	default:
+		return nil, fmt.Errorf("unsupported question kind: %v", question.Kind)
+	}
+
+	questionEventID, err := sink.client.SendEvent(sink.RoomID, "m.room.message", &matrixEventContent{
+		MsgType:       "m.text",
+		Body:          question.Text + "\n" + hint,
+		Format:        "org.matrix.custom.html",
+		FormattedBody: matrixHTML(question.Text) + "<br><em>" + matrixHTML(hint) + "</em>",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to send question: %w", err)
+	}
+	sink.QuestionStore.recordMessage(question.ID, &StoredQuestionMessage{
+		SinkID:  sink.SinkID(),
+		Channel: sink.RoomID,
+		Message: questionEventID,
+	})
+	questionAskedTime := time.Now()
+	answerChan := make(chan *Answer, 1)
+	removeListener := sink.MatrixManager.AddEventListener(sink.Homeserver, sink.AccessToken, func(roomID string, event *matrixEvent) {
+		if roomID != sink.RoomID || event.Content.RelatesTo == nil {
+			return
+		}
+		relatesTo := event.Content.RelatesTo
+		isReaction := event.Type == "m.reaction" && relatesTo.RelType == "m.annotation" && relatesTo.EventID == questionEventID
+		isReply := event.Type == "m.room.message" && relatesTo.InReplyTo != nil && relatesTo.InReplyTo.EventID == questionEventID
+		if isReaction && question.Kind != QuestionKind_YesNo || !isReaction && !isReply {
+			return
+		}
+		if !sink.isAllowedToAnswer(event.Sender) {
+			sink.sendNotice(event.EventID, "You are not allowed to answer this question")
+			return
+		}
+		var value interface{}
+		var label string
+		var ok bool
+		if isReaction {
+			value, label, ok = parseReactionAnswer(relatesTo.Key)
+			if !ok {
+				return
+			}
+		} else {
+			value, label, ok = parseTextAnswer(question, stripMatrixReplyFallback(event.Content.Body))
+			if !ok {
+				sink.sendNotice(event.EventID, "Unrecognized answer. "+hint)
+				return
+			}
+		}
+		select {
+		case answerChan <- &Answer{
+			Value:          value,
+			AnwserDuration: time.Since(questionAskedTime),
+			AnsweredBy: &Answerer{
+				Sink:        "matrix",
+				ID:          event.Sender,
+				Username:    matrixLocalpart(event.Sender),
+				DisplayName: event.Sender,
+			},
+			AnsweredAt: time.Now(),
+		}:
+			sink.sendNotice(questionEventID, fmt.Sprintf("Answered: %v (by %v)", label, event.Sender))
+		default:
+		}
+	})
+	defer removeListener()
+
+	select {
+	case answer := <-answerChan:
+		return answer, nil
+	case <-ctx.Done():
+		sink.sendNotice(questionEventID, question.TimeoutLabel())
+		return &Answer{
+			Value:          question.TimeoutValue(),
+			TimedOut:       true,
+			AnwserDuration: time.Since(questionAskedTime),
+		}, nil
+	}
+}
+
+func (sink *MatrixNotificationSink) SinkID() string {
+	return fmt.Sprintf("matrix:%v:%v", sink.client.UserID, sink.RoomID)
+}
+
+// ExpireQuestion replies to a question left over from a previous run with a
+// notice, the same way answers and timeouts are announced.
+func (sink *MatrixNotificationSink) ExpireQuestion(question *Question, message *StoredQuestionMessage) error {
+	_, err := sink.client.SendEvent(message.Channel, "m.room.message", &matrixEventContent{
+		MsgType: "m.notice",
+		Body:    questionExpiredLabel,
+		RelatesTo: &matrixRelatesTo{
+			InReplyTo: &matrixInReplyTo{EventID: message.Message},
+		},
+	})
+	return err
+}
+
+// sendNotice sends a notice replying to the given event.
+func (sink *MatrixNotificationSink) sendNotice(inReplyTo string, text string) {
+	_, err := sink.client.SendEvent(sink.RoomID, "m.room.message", &matrixEventContent{
+		MsgType: "m.notice",
+		Body:    text,
+		RelatesTo: &matrixRelatesTo{
+			InReplyTo: &matrixInReplyTo{EventID: inReplyTo},
+		},
+	})
+	if err != nil {
+		log.Printf("failed to send matrix notice: %v", err)
+	}
+}
+
+func (sink *MatrixNotificationSink) isAllowedToAnswer(userID string) bool {
+	if len(sink.AllowedUsers) == 0 {
+		return true
+	}
+	for _, allowed := range sink.AllowedUsers {
+		if allowed == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func matrixHTML(text string) string {
+	return strings.ReplaceAll(html.EscapeString(text), "\n", "<br>")
+}
+
+// matrixLocalpart returns "user" for the user ID "@user:example.com".
+func matrixLocalpart(userID string) string {
+	return strings.SplitN(strings.TrimPrefix(userID, "@"), ":", 2)[0]
+}
+
+// stripMatrixReplyFallback removes the quote of the original message which
+// clients prepend to the body of replies.
+func stripMatrixReplyFallback(body string) string {
+	lines := strings.Split(body, "\n")
+	i := 0
+	for i < len(lines) && strings.HasPrefix(lines[i], ">") {
+		i++
+	}
+	if i > 0 && i < len(lines) && lines[i] == "" {
+		i++
+	}
+	return strings.Join(lines[i:], "\n")
+}
+
+func init() {
+	registerSinkType("matrix", func(config sinkConfig, managers *sinkManagers) (NotificationSink, error) {
+		return &MatrixNotificationSink{
+			Homeserver:    config.String("homeserver"),
+			AccessToken:   config.String("access_token"),
+			RoomID:        config.String("room_id"),
+			AllowedUsers:  config.Strings("allowed_users"),
+			MatrixManager: managers.Matrix,
+			QuestionStore: managers.QuestionStore,
+		}, nil
+	})
+}
@@ -0,0 +1,227 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testHomeserver implements the parts of the client-server API used by
+// MatrixClient. Events queued on the events channel are returned by the next sync.
+type testHomeserver struct {
+	*httptest.Server
+	events chan *matrixEvent
+	sent   chan *matrixEventContent
+
+	mutex  sync.Mutex
+	nextID int
+}
+
+func newTestHomeserver(t *testing.T) *testHomeserver {
+	hs := &testHomeserver{
+		events: make(chan *matrixEvent, 16),
+		sent:   make(chan *matrixEventContent, 16),
+	}
+	hs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		path := strings.TrimPrefix(r.URL.Path, "/_matrix/client/r0")
+		switch {
+		case path == "/account/whoami":
+			json.NewEncoder(w).Encode(map[string]string{"user_id": "@notifier:example.com"})
+		case path == "/sync":
+			resp := matrixSyncResponse{NextBatch: "next"}
+			if r.URL.Query().Get("since") != "" {
+				select {
+				case event := <-hs.events:
+					resp.Rooms.Join = map[string]struct {
+						Timeline struct {
+							Events []*matrixEvent `json:"events"`
+						} `json:"timeline"`
+					}{}
+					room := resp.Rooms.Join["!room:example.com"]
+					room.Timeline.Events = []*matrixEvent{event}
+					resp.Rooms.Join["!room:example.com"] = room
+				case <-time.After(50 * time.Millisecond):
+				}
+			}
+			json.NewEncoder(w).Encode(&resp)
+		case r.Method == http.MethodPut && strings.HasPrefix(path, "/rooms/!room:example.com/send/"):
+			var content matrixEventContent
+			if err := json.NewDecoder(r.Body).Decode(&content); err != nil {
+				t.Error(err)
+			}
+			hs.mutex.Lock()
+			hs.nextID++
+			eventID := fmt.Sprintf("$event%v", hs.nextID)
+			hs.mutex.Unlock()
+			hs.sent <- &content
+			json.NewEncoder(w).Encode(map[string]string{"event_id": eventID})
+		default:
+			t.Errorf("unexpected request %v %v", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(hs.Close)
+	return hs
+}
+
+func (hs *testHomeserver) nextSent(t *testing.T) *matrixEventContent {
+	t.Helper()
+	select {
+	case content := <-hs.sent:
+		return content
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event was sent")
+		return nil
+	}
+}
+
+// waitForListener waits until a sink has started listening for events, events
+// returned by the sync before that are not dispatched to it.
+func (hs *testHomeserver) waitForListener(t *testing.T, m *MatrixManager) {
+	t.Helper()
+	key := matrixClientKey(hs.URL, "token")
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		m.listenersMutex.RLock()
+		n := len(m.eventListeners[key])
+		m.listenersMutex.RUnlock()
+		if n > 0 {
+			return
+		}
+	}
+	t.Fatal("the sink did not listen for events")
+}
+
+func newTestMatrixSink(t *testing.T, allowedUsers []string) (*MatrixNotificationSink, *testHomeserver) {
+	t.Helper()
+	hs := newTestHomeserver(t)
+	sink := &MatrixNotificationSink{
+		Homeserver:    hs.URL,
+		AccessToken:   "token",
+		RoomID:        "!room:example.com",
+		AllowedUsers:  allowedUsers,
+		MatrixManager: NewMatrixManager(),
+	}
+	if err := sink.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return sink, hs
+}
+
+func TestMatrixNotificationSinkDeliverNotification(t *testing.T) {
+	sink, hs := newTestMatrixSink(t, nil)
+	if err := sink.DeliverNotification(&Notification{Title: "Backup", Body: "<done>\nok"}); err != nil {
+		t.Fatal(err)
+	}
+	content := hs.nextSent(t)
+	if !strings.HasPrefix(content.Body, "Backup\n<done>\nok") {
+		t.Errorf("body = %q", content.Body)
+	}
+	if !strings.HasPrefix(content.FormattedBody, "<strong>Backup</strong><br>&lt;done&gt;<br>ok") {
+		t.Errorf("formatted body = %q", content.FormattedBody)
+	}
+}
+
+func TestMatrixNotificationSinkAskQuestion(t *testing.T) {
+	sink, hs := newTestMatrixSink(t, []string{"@alice:example.com"})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	answers := make(chan *Answer, 1)
+	go func() {
+		answer, err := sink.AskQuestion(ctx, &Question{Kind: QuestionKind_YesNo, Text: "Deploy?"})
+		if err != nil {
+			t.Error(err)
+		}
+		answers <- answer
+	}()
+	question := hs.nextSent(t)
+	if !strings.HasPrefix(question.Body, "Deploy?") {
+		t.Fatalf("question body = %q", question.Body)
+	}
+	hs.waitForListener(t, sink.MatrixManager)
+	reaction := func(sender string, key string) *matrixEvent {
+		return &matrixEvent{
+			Type:    "m.reaction",
+			EventID: "$reaction",
+			Sender:  sender,
+			Content: matrixEventContent{
+				RelatesTo: &matrixRelatesTo{RelType: "m.annotation", EventID: "$event1", Key: key},
+			},
+		}
+	}
+
+	// the same localpart on another homeserver is a different user
+	hs.events <- reaction("@alice:evil.example", "✅")
+	if notice := hs.nextSent(t); notice.Body != "You are not allowed to answer this question" {
+		t.Errorf("notice = %q, want a refusal", notice.Body)
+	}
+
+	reply := func(sender string, body string) *matrixEvent {
+		return &matrixEvent{
+			Type:    "m.room.message",
+			EventID: "$reply",
+			Sender:  sender,
+			Content: matrixEventContent{
+				MsgType:   "m.text",
+				Body:      "> <@notifier:example.com> Deploy?\n\n" + body,
+				RelatesTo: &matrixRelatesTo{InReplyTo: &matrixInReplyTo{EventID: "$event1"}},
+			},
+		}
+	}
+	// users who can't answer are refused before their answer is parsed
+	hs.events <- reply("@bob:example.com", "maybe")
+	if notice := hs.nextSent(t); notice.Body != "You are not allowed to answer this question" {
+		t.Errorf("notice = %q, want a refusal", notice.Body)
+	}
+	hs.events <- reply("@alice:example.com", "maybe")
+	if notice := hs.nextSent(t); !strings.HasPrefix(notice.Body, "Unrecognized answer") {
+		t.Errorf("notice = %q, want the answer to be unrecognized", notice.Body)
+	}
+
+	hs.events <- reply("@alice:example.com", "yes")
+	answer := <-answers
+	if answer.Value != true || answer.AnsweredBy.ID != "@alice:example.com" || answer.AnsweredBy.Username != "alice" {
+		t.Errorf("answer = %+v by %+v, want yes by @alice:example.com", answer, answer.AnsweredBy)
+	}
+}
+
+func TestMatrixNotificationSinkRejectsLocalparts(t *testing.T) {
+	for _, allowed := range []string{"alice", "@alice", "alice:example.com"} {
+		sink := &MatrixNotificationSink{
+			Homeserver:    "https://matrix.invalid",
+			AccessToken:   "token",
+			RoomID:        "!room:example.com",
+			AllowedUsers:  []string{allowed},
+			MatrixManager: NewMatrixManager(),
+		}
+		if err := sink.Init(); err == nil {
+			t.Errorf("Init() accepted the allowed user %q", allowed)
+		}
+	}
+}
+
+func TestStripMatrixReplyFallback(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"yes", "yes"},
+		{"> <@bot:example.com> Deploy?\n\nyes", "yes"},
+		{"> <@bot:example.com> Deploy?\n> React with ✅\n\nno way", "no way"},
+		{"> quoted only", ""},
+	}
+	for _, tt := range tests {
+		if got := stripMatrixReplyFallback(tt.body); got != tt.want {
+			t.Errorf("stripMatrixReplyFallback(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package notifier
+
+import "log"
+
+// MattermostNotificationSink posts notifications as message attachments to a
+// Mattermost incoming webhook.
+type MattermostNotificationSink struct {
+	incomingWebhook
+	Channel  string
+	Username string
+	IconURL  string
+}
+
+func (sink *MattermostNotificationSink) Init() error {
+	if err := sink.incomingWebhook.init(); err != nil {
+		return err
+	}
+	log.Printf("Successfully initialized %T", *sink)
+	return nil
+}
+
+func (sink *MattermostNotificationSink) DeliverNotification(notification *Notification) error {
+	payload := map[string]interface{}{
+		"attachments": []interface{}{
+			map[string]interface{}{
+				"fallback": notification.Title + "\n" + notification.Body,
+				"color":    severityColor(notification.Severity),
+				"title":    notification.Title,
+				"text":     notification.Body,
+				"footer":   formatDate(notification.Timestamp),
+			},
+		},
+	}
+	// empty values would override the defaults of the webhook
+	if sink.Channel != "" {
+		payload["channel"] = sink.Channel
+	}
+	if sink.Username != "" {
+		payload["username"] = sink.Username
+	}
+	if sink.IconURL != "" {
+		payload["icon_url"] = sink.IconURL
+	}
+	return sink.post(payload)
+}
+
+func init() {
+	registerSinkType("mattermost", func(config sinkConfig, managers *sinkManagers) (NotificationSink, error) {
+		return &MattermostNotificationSink{
+			incomingWebhook: incomingWebhook{WebhookURL: config.String("webhook_url")},
+			Channel:         config.String("channel"),
+			Username:        config.String("username"),
+			IconURL:         config.String("icon_url"),
+		}, nil
+	})
+}
@@ -0,0 +1,51 @@
+package notifier
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMattermostNotificationSink(t *testing.T) {
+	tests := []struct {
+		name       string
+		sink       MattermostNotificationSink
+		wantFields map[string]interface{}
+	}{
+		{
+			name:       "webhook defaults",
+			wantFields: map[string]interface{}{},
+		},
+		{
+			name: "overrides",
+			sink: MattermostNotificationSink{Channel: "town-square", Username: "notifier", IconURL: "https://example.com/icon.png"},
+			wantFields: map[string]interface{}{
+				"channel":  "town-square",
+				"username": "notifier",
+				"icon_url": "https://example.com/icon.png",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, payloads := newTestIncomingWebhook(t, http.StatusOK)
+			sink := tt.sink
+			sink.WebhookURL = server.URL
+			if err := sink.Init(); err != nil {
+				t.Fatal(err)
+			}
+			if err := sink.DeliverNotification(&Notification{Title: "Disk", Body: "full", Severity: Severity_Critical}); err != nil {
+				t.Fatal(err)
+			}
+			payload := <-payloads
+			attachment := firstAttachment(t, payload)
+			if attachment["title"] != "Disk" || attachment["text"] != "full" || attachment["fallback"] != "Disk\nfull" || attachment["color"] != "#d32f2f" {
+				t.Errorf("attachment = %v", attachment)
+			}
+			for _, field := range []string{"channel", "username", "icon_url"} {
+				if payload[field] != tt.wantFields[field] {
+					t.Errorf("%v = %v, want %v", field, payload[field], tt.wantFields[field])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,373 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	mqttDefaultTopic         = "notifier/notifications"
+	mqttDefaultQuestionTopic = "notifier/questions/{{ .ID }}"
+	mqttDefaultResponseTopic = "notifier/questions/{{ .ID }}/response"
+	mqttOperationTimeout     = 10 * time.Second
+)
+
+// MqttNotificationSink publishes notifications as JSON to an MQTT broker.
+// Questions are published to the question topic, and answered by publishing
+// to the response topic of the question, so dashboards like Home Assistant or
+// Node-RED can answer them. The topics are text/templates which get the
+// Notification or the Question as their data.
+type MqttNotificationSink struct {
+	Broker                string // e.g. tcp://localhost:1883 or ssl://broker.example.com:8883
+	ClientID              string
+	Username              string
+	Password              string
+	Topic                 string
+	QuestionTopic         string
+	ResponseTopic         string
+	QoS                   byte
+	Retain                bool
+	CAFile                string
+	CertFile              string
+	KeyFile               string
+	InsecureSkipVerify    bool
+	QuestionStore         *QuestionStore
+	client                mqtt.Client
+	topicTemplate         *template.Template
+	questionTopicTemplate *template.Template
+	responseTopicTemplate *template.Template
+
+	mutex         sync.Mutex
+	subscriptions map[string]mqtt.MessageHandler // response topics of the pending questions
+}
+
+type mqttQuestionMessage struct {
+	*Question
+	ResponseTopic string `json:"responseTopic"`
+}
+
+type mqttQuestionStatusMessage struct {
+	ID     string  `json:"id"`
+	Status string  `json:"status"`
+	Answer *Answer `json:"answer"`
+}
+
+// mqttResponse is the JSON form of a message on the response topic. Plain
+// text payloads are accepted as well and used as the value.
+type mqttResponse struct {
+	Value interface{} `json:"value"`
+	User  string      `json:"user"`
+}
+
+func (sink *MqttNotificationSink) Init() error {
+	if sink.Broker == "" {
+		return fmt.Errorf("broker is not set")
+	}
+	if sink.QoS > 2 {
+		return fmt.Errorf("qos must be 0, 1 or 2")
+	}
+	if sink.Topic == "" {
+		sink.Topic = mqttDefaultTopic
+	}
+	if sink.QuestionTopic == "" {
+		sink.QuestionTopic = mqttDefaultQuestionTopic
+	}
+	if sink.ResponseTopic == "" {
+		sink.ResponseTopic = mqttDefaultResponseTopic
+	}
+	if sink.ClientID == "" {
+		sink.ClientID = "notifier-" + generateID()
+	}
+	var err error
+	if sink.topicTemplate, err = template.New("topic").Parse(sink.Topic); err != nil {
+		return fmt.Errorf("failed to parse topic: %w", err)
+	}
+	if sink.questionTopicTemplate, err = template.New("question_topic").Parse(sink.QuestionTopic); err != nil {
+		return fmt.Errorf("failed to parse question_topic: %w", err)
+	}
+	if sink.responseTopicTemplate, err = template.New("response_topic").Parse(sink.ResponseTopic); err != nil {
+		return fmt.Errorf("failed to parse response_topic: %w", err)
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(sink.Broker).
+		SetClientID(sink.ClientID).
+		SetUsername(sink.Username).
+		SetPassword(sink.Password).
+		SetAutoReconnect(true).
+		SetOnConnectHandler(sink.resubscribe).
+		SetConnectTimeout(mqttOperationTimeout).
+		SetConnectionLostHandler(func(c mqtt.Client, err error) {
+			log.Printf("Lost connection to MQTT broker %v: %v", sink.Broker, err)
+		})
+	tlsConfig, err := sink.tlsConfig()
+	if err != nil {
+		return err
+	}
+	if tlsConfig != nil {
+		opts.SetTLSConfig(tlsConfig)
+	}
+	sink.subscriptions = make(map[string]mqtt.MessageHandler)
+	sink.client = mqtt.NewClient(opts)
+	if err := sink.wait(sink.client.Connect()); err != nil {
+		return fmt.Errorf("failed to connect to %v: %w", sink.Broker, err)
+	}
+	log.Printf("Successfully initialized %T", sink)
+	return nil
+}
+
+func (sink *MqttNotificationSink) tlsConfig() (*tls.Config, error) {
+	if sink.CAFile == "" && sink.CertFile == "" && !sink.InsecureSkipVerify {
+		return nil, nil
+	}
+	config := &tls.Config{
+		InsecureSkipVerify: sink.InsecureSkipVerify,
+	}
+	if sink.CAFile != "" {
+		ca, err := os.ReadFile(sink.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca_file: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in ca_file")
+		}
+	}
+	if sink.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(sink.CertFile, sink.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+func (sink *MqttNotificationSink) wait(token mqtt.Token) error {
+	if !token.WaitTimeout(mqttOperationTimeout) {
+		return fmt.Errorf("timed out waiting for the broker")
+	}
+	return token.Error()
+}
+
+// subscribe subscribes to the topic until unsubscribe is called, also across reconnects.
+func (sink *MqttNotificationSink) subscribe(topic string, handler mqtt.MessageHandler) error {
+	sink.mutex.Lock()
+	sink.subscriptions[topic] = handler
+	sink.mutex.Unlock()
+	if err := sink.wait(sink.client.Subscribe(topic, sink.QoS, handler)); err != nil {
+		sink.mutex.Lock()
+		delete(sink.subscriptions, topic)
+		sink.mutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (sink *MqttNotificationSink) unsubscribe(topic string) error {
+	sink.mutex.Lock()
+	delete(sink.subscriptions, topic)
+	sink.mutex.Unlock()
+	return sink.wait(sink.client.Unsubscribe(topic))
+}
+
+// resubscribe is called after every connection to the broker. The session is
+// clean, so the broker has forgotten the subscriptions of the previous connection.
+func (sink *MqttNotificationSink) resubscribe(client mqtt.Client) {
+	sink.mutex.Lock()
+	subscriptions := make(map[string]mqtt.MessageHandler, len(sink.subscriptions))
+	for topic, handler := range sink.subscriptions {
+		subscriptions[topic] = handler
+	}
+	sink.mutex.Unlock()
+	for topic, handler := range subscriptions {
+		if err := sink.wait(client.Subscribe(topic, sink.QoS, handler)); err != nil {
+			log.Printf("failed to resubscribe to %v: %v", topic, err)
+		}
+	}
+}
+
+func (sink *MqttNotificationSink) publish(topic string, payload interface{}, retain bool) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return sink.wait(sink.client.Publish(topic, sink.QoS, retain, body))
+}
+
+func renderTopic(tmpl *template.Template, data interface{}) (string, error) {
+	var topic bytes.Buffer
+	if err := tmpl.Execute(&topic, data); err != nil {
+		return "", fmt.Errorf("failed to render topic: %w", err)
+	}
+	return topic.String(), nil
+}
+
+func (sink *MqttNotificationSink) DeliverNotification(notification *Notification) error {
+	topic, err := renderTopic(sink.topicTemplate, notification)
+	if err != nil {
+		return err
+	}
+	return sink.publish(topic, notification, sink.Retain)
+}
+
+func (sink *MqttNotificationSink) AskQuestion(ctx context.Context, question *Question) (*Answer, error) {
+	questionTopic, err := renderTopic(sink.questionTopicTemplate, question)
+	if err != nil {
+		return nil, err
+	}
+	responseTopic, err := renderTopic(sink.responseTopicTemplate, question)
+	if err != nil {
+		return nil, err
+	}
+	questionAskedTime := time.Now()
+	answerChan := make(chan *Answer, 1)
+	err = sink.subscribe(responseTopic, func(c mqtt.Client, msg mqtt.Message) {
+		value, user, ok := parseMqttResponse(question, msg.Payload())
+		if !ok {
+			log.Printf("ignoring invalid answer to question %v on %v: %q", question.ID, msg.Topic(), msg.Payload())
+			return
+		}
+		select {
+		case answerChan <- &Answer{
+			Value:          value,
+			AnwserDuration: time.Since(questionAskedTime),
+			AnsweredBy: &Answerer{
+				Sink:     "mqtt",
+				ID:       user,
+				Username: user,
+			},
+			AnsweredAt: time.Now(),
+		}:
+		default:
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to %v: %w", responseTopic, err)
+	}
+	defer func() {
+		if err := sink.unsubscribe(responseTopic); err != nil {
+			log.Printf("failed to unsubscribe from %v: %v", responseTopic, err)
+		}
+	}()
+
+	err = sink.publish(questionTopic, &mqttQuestionMessage{
+		Question:      question,
+		ResponseTopic: responseTopic,
+	}, sink.Retain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send question: %w", err)
+	}
+	sink.QuestionStore.recordMessage(question.ID, &StoredQuestionMessage{
+		SinkID:  sink.SinkID(),
+		Channel: questionTopic,
+	})
+
+	var answer *Answer
+	status := "answered"
+	select {
+	case answer = <-answerChan:
+	case <-ctx.Done():
+		status = "timedOut"
+		answer = &Answer{
+			Value:          question.TimeoutValue(),
+			TimedOut:       true,
+			AnwserDuration: time.Since(questionAskedTime),
+		}
+	}
+	// replaces the question on its topic, so dashboards stop offering to answer it
+	err = sink.publish(questionTopic, &mqttQuestionStatusMessage{
+		ID:     question.ID,
+		Status: status,
+		Answer: answer,
+	}, sink.Retain)
+	if err != nil {
+		log.Printf("failed to publish the status of question %v: %v", question.ID, err)
+	}
+	return answer, nil
+}
+
+func (sink *MqttNotificationSink) SinkID() string {
+	return fmt.Sprintf("mqtt:%v", sink.Broker)
+}
+
+// ExpireQuestion replaces a question left over from a previous run on its topic.
+func (sink *MqttNotificationSink) ExpireQuestion(question *Question, message *StoredQuestionMessage) error {
+	return sink.publish(message.Channel, &mqttQuestionStatusMessage{
+		ID:     question.ID,
+		Status: "expired",
+	}, sink.Retain)
+}
+
+func parseMqttResponse(question *Question, payload []byte) (interface{}, string, bool) {
+	var resp mqttResponse
+	if err := json.Unmarshal(payload, &resp); err != nil || resp.Value == nil {
+		resp = mqttResponse{Value: strings.TrimSpace(string(payload))}
+	}
+	switch question.Kind {
+	case QuestionKind_YesNo:
+		switch v := resp.Value.(type) {
+		case bool:
+			return v, resp.User, true
+		case string:
+			switch strings.ToLower(v) {
+			case "yes", "y", "true", "on":
+				return true, resp.User, true
+			case "no", "n", "false", "off":
+				return false, resp.User, true
+			}
+		}
+	case QuestionKind_Choice:
+		v := fmt.Sprintf("%v", resp.Value)
+		for _, option := range question.Options {
+			if option.Value == v || strings.EqualFold(option.Label, v) {
+				return option.Value, resp.User, true
+			}
+		}
+	case QuestionKind_Text:
+		if v, ok := resp.Value.(string); ok && v != "" {
+			return v, resp.User, true
+		}
+	}
+	return nil, "", false
+}
+
+func init() {
+	registerSinkType("mqtt", func(config sinkConfig, managers *sinkManagers) (NotificationSink, error) {
+		qos, err := config.Int("qos")
+		if err != nil {
+			return nil, err
+		}
+		if qos < 0 || qos > 2 {
+			return nil, fmt.Errorf("qos must be 0, 1 or 2")
+		}
+		return &MqttNotificationSink{
+			Broker:             config.String("broker"),
+			ClientID:           config.String("client_id"),
+			Username:           config.String("username"),
+			Password:           config.String("password"),
+			Topic:              config.String("topic"),
+			QuestionTopic:      config.String("question_topic"),
+			ResponseTopic:      config.String("response_topic"),
+			QoS:                byte(qos),
+			Retain:             config.Bool("retain"),
+			CAFile:             config.String("ca_file"),
+			CertFile:           config.String("cert_file"),
+			KeyFile:            config.String("key_file"),
+			InsecureSkipVerify: config.Bool("insecure_skip_verify"),
+			QuestionStore:      managers.QuestionStore,
+		}, nil
+	})
+}
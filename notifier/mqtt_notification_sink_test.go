@@ -0,0 +1,285 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+type testMqttMessage struct {
+	Topic   string
+	Payload string
+}
+
+type testMqttSubscription struct {
+	Conn  int
+	Topic string
+}
+
+type testMqttConn struct {
+	id            int
+	conn          net.Conn
+	writeMutex    sync.Mutex
+	subscriptions map[string]bool
+}
+
+func (c *testMqttConn) write(packet packets.ControlPacket) {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	packet.Write(c.conn)
+}
+
+// testMqttBroker is a minimal MQTT 3.1.1 broker. It only supports exact topic
+// matches and clean sessions, subscriptions are forgotten with the connection.
+type testMqttBroker struct {
+	listener   net.Listener
+	published  chan testMqttMessage
+	subscribed chan testMqttSubscription
+
+	mutex    sync.Mutex
+	conns    map[*testMqttConn]bool
+	lastConn int
+}
+
+func startTestMqttBroker(t *testing.T) *testMqttBroker {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker := &testMqttBroker{
+		listener:   listener,
+		published:  make(chan testMqttMessage, 16),
+		subscribed: make(chan testMqttSubscription, 16),
+		conns:      make(map[*testMqttConn]bool),
+	}
+	t.Cleanup(func() {
+		listener.Close()
+		broker.dropConnections()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go broker.serve(&testMqttConn{conn: conn, subscriptions: make(map[string]bool)})
+		}
+	}()
+	return broker
+}
+
+func (broker *testMqttBroker) serve(c *testMqttConn) {
+	broker.mutex.Lock()
+	broker.lastConn++
+	c.id = broker.lastConn
+	broker.conns[c] = true
+	broker.mutex.Unlock()
+	defer func() {
+		broker.mutex.Lock()
+		delete(broker.conns, c)
+		broker.mutex.Unlock()
+		c.conn.Close()
+	}()
+	for {
+		packet, err := packets.ReadPacket(c.conn)
+		if err != nil {
+			return
+		}
+		switch p := packet.(type) {
+		case *packets.ConnectPacket:
+			c.write(packets.NewControlPacket(packets.Connack))
+		case *packets.SubscribePacket:
+			broker.mutex.Lock()
+			for _, topic := range p.Topics {
+				c.subscriptions[topic] = true
+			}
+			broker.mutex.Unlock()
+			ack := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			ack.MessageID = p.MessageID
+			ack.ReturnCodes = p.Qoss
+			c.write(ack)
+			for _, topic := range p.Topics {
+				broker.subscribed <- testMqttSubscription{c.id, topic}
+			}
+		case *packets.UnsubscribePacket:
+			broker.mutex.Lock()
+			for _, topic := range p.Topics {
+				delete(c.subscriptions, topic)
+			}
+			broker.mutex.Unlock()
+			ack := packets.NewControlPacket(packets.Unsuback).(*packets.UnsubackPacket)
+			ack.MessageID = p.MessageID
+			c.write(ack)
+		case *packets.PublishPacket:
+			if p.Qos == 1 {
+				ack := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				ack.MessageID = p.MessageID
+				c.write(ack)
+			}
+			broker.publish(p.TopicName, p.Payload)
+			broker.published <- testMqttMessage{p.TopicName, string(p.Payload)}
+		case *packets.PingreqPacket:
+			c.write(packets.NewControlPacket(packets.Pingresp))
+		case *packets.DisconnectPacket:
+			return
+		}
+	}
+}
+
+// publish sends the message to all clients subscribed to the topic.
+func (broker *testMqttBroker) publish(topic string, payload []byte) {
+	broker.mutex.Lock()
+	var subscribers []*testMqttConn
+	for c := range broker.conns {
+		if c.subscriptions[topic] {
+			subscribers = append(subscribers, c)
+		}
+	}
+	broker.mutex.Unlock()
+	for _, c := range subscribers {
+		packet := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+		packet.TopicName = topic
+		packet.Payload = payload
+		c.write(packet)
+	}
+}
+
+// dropConnections closes the connections of all clients, as if the broker restarted.
+func (broker *testMqttBroker) dropConnections() {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+	for c := range broker.conns {
+		c.conn.Close()
+	}
+}
+
+func (broker *testMqttBroker) nextPublished(t *testing.T) testMqttMessage {
+	t.Helper()
+	select {
+	case msg := <-broker.published:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing was published")
+		return testMqttMessage{}
+	}
+}
+
+// waitForSubscription waits until a client connected after the connection
+// afterConn subscribes to the topic and returns the connection.
+func (broker *testMqttBroker) waitForSubscription(t *testing.T, topic string, afterConn int) int {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case subscribed := <-broker.subscribed:
+			if subscribed.Topic == topic && subscribed.Conn > afterConn {
+				return subscribed.Conn
+			}
+		case <-timeout:
+			t.Fatalf("nobody subscribed to %v", topic)
+			return 0
+		}
+	}
+}
+
+func TestMqttNotificationSinkAskQuestionAfterReconnect(t *testing.T) {
+	broker := startTestMqttBroker(t)
+	sink := &MqttNotificationSink{
+		Broker:        "tcp://" + broker.listener.Addr().String(),
+		QuestionTopic: "questions/{{.ID}}",
+		ResponseTopic: "questions/{{.ID}}/response",
+	}
+	if err := sink.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sink.client.Disconnect(0) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	type result struct {
+		answer *Answer
+		err    error
+	}
+	results := make(chan result, 1)
+	go func() {
+		answer, err := sink.AskQuestion(ctx, &Question{ID: "q1", Kind: QuestionKind_YesNo, Text: "Deploy?"})
+		results <- result{answer, err}
+	}()
+	conn := broker.waitForSubscription(t, "questions/q1/response", 0)
+	if msg := broker.nextPublished(t); msg.Topic != "questions/q1" {
+		t.Fatalf("question published on %v, want questions/q1", msg.Topic)
+	}
+
+	broker.dropConnections()
+	broker.waitForSubscription(t, "questions/q1/response", conn)
+	broker.publish("questions/q1/response", []byte(`{"value": "yes", "user": "alice"}`))
+
+	r := <-results
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if r.answer.Value != true || r.answer.TimedOut {
+		t.Errorf("answer = %v (timed out: %v), want true", r.answer.Value, r.answer.TimedOut)
+	}
+	if r.answer.AnsweredBy.ID != "alice" {
+		t.Errorf("answered by %q, want %q", r.answer.AnsweredBy.ID, "alice")
+	}
+	if msg := broker.nextPublished(t); msg.Topic != "questions/q1" {
+		t.Errorf("status published on %v, want questions/q1", msg.Topic)
+	}
+}
+
+func TestMqttNotificationSinkExpireQuestion(t *testing.T) {
+	broker := startTestMqttBroker(t)
+	storePath := filepath.Join(t.TempDir(), "questions.json")
+	store := NewQuestionStore(storePath)
+	sink := &MqttNotificationSink{
+		Broker:        "tcp://" + broker.listener.Addr().String(),
+		QuestionTopic: "questions/{{.ID}}",
+		QuestionStore: store,
+	}
+	if err := sink.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sink.client.Disconnect(0) })
+	question := &Question{ID: "q1", Kind: QuestionKind_YesNo, Text: "Deploy?"}
+	if err := store.Add(question, "alice", time.Now().Add(time.Hour), ""); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sink.AskQuestion(ctx, question)
+		close(done)
+	}()
+	broker.nextPublished(t)
+	cancel()
+	<-done
+	broker.nextPublished(t)
+
+	stored, err := NewQuestionStore(storePath).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 1 || len(stored[0].Messages) != 1 || stored[0].Messages[0].Channel != "questions/q1" {
+		t.Fatalf("stored = %+v, want the question with its topic", stored)
+	}
+	if err := sink.ExpireQuestion(stored[0].Question, stored[0].Messages[0]); err != nil {
+		t.Fatal(err)
+	}
+	msg := broker.nextPublished(t)
+	var status mqttQuestionStatusMessage
+	if err := json.Unmarshal([]byte(msg.Payload), &status); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Topic != "questions/q1" || status.ID != "q1" || status.Status != "expired" {
+		t.Errorf("published %v on %v, want the expired status on questions/q1", msg.Payload, msg.Topic)
+	}
+}
@@ -1,9 +1,62 @@
 package notifier
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
+
+// Severity is the importance of a notification, named after the syslog severities.
+type Severity string
+
+var (
+	Severity_Emergency Severity = "emergency"
+	Severity_Alert     Severity = "alert"
+	Severity_Critical  Severity = "critical"
+	Severity_Error     Severity = "error"
+	Severity_Warning   Severity = "warning"
+	Severity_Notice    Severity = "notice"
+	Severity_Info      Severity = "info"
+	Severity_Debug     Severity = "debug"
+)
+
+// severityLevels are ordered like the syslog severity values, from 0 (emergency) to 7 (debug).
+var severityLevels = []Severity{
+	Severity_Emergency,
+	Severity_Alert,
+	Severity_Critical,
+	Severity_Error,
+	Severity_Warning,
+	Severity_Notice,
+	Severity_Info,
+	Severity_Debug,
+}
+
+// SyslogLevel returns the syslog severity value of the severity.
+func (s Severity) SyslogLevel() (int, error) {
+	for i, level := range severityLevels {
+		if level == s {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity: %q", s)
+}
+
+// NotificationAction tells incident management sinks what to do with the
+// incident identified by the dedup key of a notification.
+type NotificationAction string
+
+var (
+	// NotificationAction_Trigger opens an incident, or adds to the open incident with the same dedup key.
+	NotificationAction_Trigger NotificationAction = "trigger"
+	// NotificationAction_Resolve resolves the incident with the dedup key of the notification.
+	NotificationAction_Resolve NotificationAction = "resolve"
+)
 
 type Notification struct {
-	Timestamp time.Time `json:"timestamp"`
-	Title     string    `json:"title"`
-	Body      string    `json:"body"`
+	Timestamp time.Time          `json:"timestamp"`
+	Title     string             `json:"title"`
+	Body      string             `json:"body"`
+	Severity  Severity           `json:"severity,omitempty"`
+	DedupKey  string             `json:"dedupKey,omitempty"`
+	Action    NotificationAction `json:"action,omitempty"`
 }
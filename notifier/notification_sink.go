@@ -3,6 +3,7 @@ package notifier
 import "context"
 
 type NotificationSink interface {
+	Init() error
 	DeliverNotification(notification *Notification) error
 }
 
@@ -10,3 +11,19 @@ type NotificationSinkWithQuestions interface {
 	NotificationSink
 	AskQuestion(ctx context.Context, question *Question) (*Answer, error)
 }
+
+// NotificationSinkWithPersistentQuestions is implemented by sinks which record
+// their question messages in a QuestionStore, so that they can be marked as
+// expired after a restart.
+type NotificationSinkWithPersistentQuestions interface {
+	NotificationSinkWithQuestions
+	SinkID() string
+	ExpireQuestion(question *Question, message *StoredQuestionMessage) error
+}
+
+// NotificationSinkWithIncidents is implemented by incident management sinks.
+// Notifications with the resolve action are delivered only to them.
+type NotificationSinkWithIncidents interface {
+	NotificationSink
+	ManagesIncidents()
+}
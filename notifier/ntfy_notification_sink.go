@@ -0,0 +1,204 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	ntfyDefaultServerURL = "https://ntfy.sh"
+	ntfyMaxActionCount   = 3
+)
+
+// NtfyNotificationSink publishes notifications to a topic of an ntfy server.
+// Questions are asked with action buttons, which answer them by calling the
+// answer links of the HTTP server at http.public_url.
+type NtfyNotificationSink struct {
+	ServerURL   string
+	Topic       string
+	Priority    int // 1 (min) to 5 (max), the server default when 0
+	Tags        []string
+	Token       string
+	Username    string
+	Password    string
+	AnswerLinks *AnswerLinkManager
+	client      *http.Client
+}
+
+type ntfyAction struct {
+	Action string `json:"action"`
+	Label  string `json:"label"`
+	URL    string `json:"url"`
+	Method string `json:"method,omitempty"`
+	Clear  bool   `json:"clear,omitempty"`
+}
+
+type ntfyMessage struct {
+	Topic    string        `json:"topic"`
+	Title    string        `json:"title,omitempty"`
+	Message  string        `json:"message"`
+	Priority int           `json:"priority,omitempty"`
+	Tags     []string      `json:"tags,omitempty"`
+	Actions  []*ntfyAction `json:"actions,omitempty"`
+}
+
+func (sink *NtfyNotificationSink) Init() error {
+	if sink.Topic == "" {
+		return fmt.Errorf("topic is not set")
+	}
+	if sink.Priority < 0 || sink.Priority > 5 {
+		return fmt.Errorf("priority must be between 1 and 5")
+	}
+	if sink.ServerURL == "" {
+		sink.ServerURL = ntfyDefaultServerURL
+	}
+	sink.ServerURL = strings.TrimSuffix(sink.ServerURL, "/")
+	sink.client = &http.Client{
+		Timeout: 10 * time.Second,
+	}
+	log.Printf("Successfully initialized %T", *sink)
+	return nil
+}
+
+func (sink *NtfyNotificationSink) DeliverNotification(notification *Notification) error {
+	return sink.publish(&ntfyMessage{
+		Topic:    sink.Topic,
+		Title:    notification.Title,
+		Message:  notification.Body,
+		Priority: sink.Priority,
+		Tags:     sink.Tags,
+	})
+}
+
+func (sink *NtfyNotificationSink) AskQuestion(ctx context.Context, question *Question) (*Answer, error) {
+	if sink.AnswerLinks == nil {
+		return nil, fmt.Errorf("answer links are not available")
+	}
+	labels := []string{}
+	values := []interface{}{}
+	switch question.Kind {
+	case QuestionKind_YesNo:
+		labels = append(labels, "Yes", "No")
+		values = append(values, true, false)
+	case QuestionKind_Choice:
+		if len(question.Options) > ntfyMaxActionCount {
+			return nil, fmt.Errorf("ntfy supports at most %v options", ntfyMaxActionCount)
+		}
+		for _, option := range question.Options {
+			labels = append(labels, option.Label)
+			values = append(values, option.Value)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported question kind: %v", question.Kind)
+	}
+	expires, ok := ctx.Deadline()
+	if !ok {
+		expires = time.Now().Add(time.Hour * 100000)
+	}
+	questionAskedTime := time.Now()
+	answerChan := make(chan *Answer, 1)
+	listenerID, removeListener := sink.AnswerLinks.AddListener(question, func(answer *Answer) {
+		select {
+		case answerChan <- answer:
+		default:
+		}
+	})
+	defer removeListener()
+
+	answerer := &Answerer{
+		Sink:     "ntfy",
+		ID:       sink.Topic,
+		Username: sink.Topic,
+	}
+	actions := []*ntfyAction{}
+	for i, label := range labels {
+		link, err := sink.AnswerLinks.CreateLink(listenerID, values[i], answerer, expires)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, &ntfyAction{
+			Action: "http",
+			Label:  label,
+			URL:    link,
+			Method: http.MethodPost,
+			Clear:  true,
+		})
+	}
+	err := sink.publish(&ntfyMessage{
+		Topic:    sink.Topic,
+		Title:    "Question",
+		Message:  question.Text,
+		Priority: sink.Priority,
+		Tags:     append(append([]string{}, sink.Tags...), "question"),
+		Actions:  actions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to send question: %w", err)
+	}
+
+	select {
+	case answer := <-answerChan:
+		return answer, nil
+	case <-ctx.Done():
+		return &Answer{
+			Value:          question.TimeoutValue(),
+			TimedOut:       true,
+			AnwserDuration: time.Since(questionAskedTime),
+		}, nil
+	}
+}
+
+// publish sends the message as JSON to the root of the server, which lets
+// the topic, tags and actions be passed without header encoding issues.
+func (sink *NtfyNotificationSink) publish(msg *ntfyMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, sink.ServerURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if sink.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+sink.Token)
+	} else if sink.Username != "" {
+		req.SetBasicAuth(sink.Username, sink.Password)
+	}
+	resp, err := sink.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("ntfy responded with status %v: %v", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+func init() {
+	registerSinkType("ntfy", func(config sinkConfig, managers *sinkManagers) (NotificationSink, error) {
+		priority, err := config.Int("priority")
+		if err != nil {
+			return nil, err
+		}
+		return &NtfyNotificationSink{
+			ServerURL:   config.String("server_url"),
+			Topic:       config.String("topic"),
+			Priority:    priority,
+			Tags:        config.Strings("tags"),
+			Token:       config.String("token"),
+			Username:    config.String("username"),
+			Password:    config.String("password"),
+			AnswerLinks: managers.AnswerLinks,
+		}, nil
+	})
+}
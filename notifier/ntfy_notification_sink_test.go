@@ -0,0 +1,118 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// newTestAnswerLinks returns an AnswerLinkManager creating links to public_url.
+func newTestAnswerLinks(t *testing.T) *AnswerLinkManager {
+	t.Helper()
+	viper.Set("http.jwt_secret", "test secret")
+	viper.Set("http.public_url", "https://notifier.example.com")
+	t.Cleanup(func() {
+		viper.Set("http.jwt_secret", "")
+		viper.Set("http.public_url", "")
+	})
+	return NewAnswerLinkManager()
+}
+
+// answerLinkToken returns the token of an answer link.
+func answerLinkToken(t *testing.T, link string) string {
+	t.Helper()
+	token := strings.TrimPrefix(link, "https://notifier.example.com/answer/")
+	if token == link {
+		t.Fatalf("%v is not an answer link", link)
+	}
+	return token
+}
+
+func newTestNtfyServer(t *testing.T, messages chan<- *ntfyMessage) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tk_test" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var msg ntfyMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Error(err)
+		}
+		messages <- &msg
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNtfyNotificationSinkDeliverNotification(t *testing.T) {
+	messages := make(chan *ntfyMessage, 1)
+	server := newTestNtfyServer(t, messages)
+	sink := &NtfyNotificationSink{ServerURL: server.URL + "/", Topic: "alerts", Priority: 4, Tags: []string{"warning"}, Token: "tk_test"}
+	if err := sink.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.DeliverNotification(&Notification{Title: "Disk", Body: "full"}); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-messages
+	if msg.Topic != "alerts" || msg.Title != "Disk" || msg.Message != "full" || msg.Priority != 4 || len(msg.Tags) != 1 {
+		t.Errorf("message = %+v", msg)
+	}
+
+	sink.Token = "wrong"
+	if err := sink.DeliverNotification(&Notification{Body: "full"}); err == nil {
+		t.Error("DeliverNotification() succeeded on a 403 response")
+	}
+}
+
+func TestNtfyNotificationSinkAskQuestion(t *testing.T) {
+	messages := make(chan *ntfyMessage, 1)
+	server := newTestNtfyServer(t, messages)
+	answerLinks := newTestAnswerLinks(t)
+	sink := &NtfyNotificationSink{ServerURL: server.URL, Topic: "alerts", Token: "tk_test", AnswerLinks: answerLinks}
+	if err := sink.Init(); err != nil {
+		t.Fatal(err)
+	}
+	question := &Question{
+		Kind:    QuestionKind_Choice,
+		Text:    "Which?",
+		Options: []QuestionOption{{Label: "First", Value: "first"}, {Label: "Second", Value: "second"}},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	answers := make(chan *Answer, 1)
+	go func() {
+		answer, err := sink.AskQuestion(ctx, question)
+		if err != nil {
+			t.Error(err)
+		}
+		answers <- answer
+	}()
+	msg := <-messages
+	if len(msg.Actions) != 2 || msg.Actions[1].Label != "Second" || msg.Actions[1].Method != http.MethodPost {
+		t.Fatalf("actions = %+v", msg.Actions)
+	}
+	if _, err := answerLinks.Resolve(answerLinkToken(t, msg.Actions[1].URL), ""); err != nil {
+		t.Fatal(err)
+	}
+	if answer := <-answers; answer.Value != "second" || answer.AnsweredBy.Sink != "ntfy" {
+		t.Errorf("answer = %+v", answer)
+	}
+}
+
+func TestNtfyNotificationSinkTooManyOptions(t *testing.T) {
+	sink := &NtfyNotificationSink{Topic: "alerts", AnswerLinks: newTestAnswerLinks(t)}
+	if err := sink.Init(); err != nil {
+		t.Fatal(err)
+	}
+	question := &Question{Kind: QuestionKind_Choice, Text: "Which?", Options: make([]QuestionOption, ntfyMaxActionCount+1)}
+	if _, err := sink.AskQuestion(context.Background(), question); err == nil {
+		t.Error("AskQuestion accepted more options than ntfy has buttons")
+	}
+}
@@ -0,0 +1,166 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	opsgenieDefaultAPIURL  = "https://api.opsgenie.com"
+	opsgenieMaxAliasLength = 512
+)
+
+// OpsgenieNotificationSink creates and closes Opsgenie alerts. The dedup key
+// of a notification is used as the alias of the alert, so that Opsgenie
+// deduplicates the alerts and a notification with the resolve action can
+// close it.
+type OpsgenieNotificationSink struct {
+	APIKey   string
+	APIURL   string // https://api.eu.opsgenie.com for the EU instance
+	Source   string
+	Tags     []string
+	Severity Severity // used for notifications without a severity
+	client   *http.Client
+}
+
+type opsgenieAlert struct {
+	Message     string   `json:"message"`
+	Alias       string   `json:"alias,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Priority    string   `json:"priority"`
+	Source      string   `json:"source,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+type opsgenieCloseRequest struct {
+	Source string `json:"source,omitempty"`
+	Note   string `json:"note,omitempty"`
+}
+
+func (sink *OpsgenieNotificationSink) Init() error {
+	if sink.APIKey == "" {
+		return fmt.Errorf("api_key is not set")
+	}
+	if sink.APIURL == "" {
+		sink.APIURL = opsgenieDefaultAPIURL
+	}
+	sink.APIURL = strings.TrimSuffix(sink.APIURL, "/")
+	if sink.Source == "" {
+		sink.Source = "notifier"
+	}
+	if sink.Severity == "" {
+		sink.Severity = Severity_Error
+	}
+	if _, err := sink.Severity.SyslogLevel(); err != nil {
+		return err
+	}
+	sink.client = &http.Client{
+		Timeout: 10 * time.Second,
+	}
+	log.Printf("Successfully initialized %T", *sink)
+	return nil
+}
+
+func (sink *OpsgenieNotificationSink) ManagesIncidents() {}
+
+func (sink *OpsgenieNotificationSink) DeliverNotification(notification *Notification) error {
+	if notification.Action == NotificationAction_Resolve {
+		if notification.DedupKey == "" {
+			return fmt.Errorf("a dedup key is required to resolve an incident")
+		}
+		return sink.call(fmt.Sprintf("/v2/alerts/%v/close?identifierType=alias", url.PathEscape(opsgenieAlias(notification.DedupKey))), &opsgenieCloseRequest{
+			Source: sink.Source,
+			Note:   notification.Body,
+		})
+	}
+	message := notification.Title
+	if message == "" {
+		message = notification.Body
+	}
+	severity := notification.Severity
+	if severity == "" {
+		severity = sink.Severity
+	}
+	return sink.call("/v2/alerts", &opsgenieAlert{
+		Message:     truncateText(message, 130),
+		Alias:       opsgenieAlias(notification.DedupKey),
+		Description: truncateText(notification.Body, 15000),
+		Priority:    opsgeniePriority(severity),
+		Source:      sink.Source,
+		Tags:        sink.Tags,
+	})
+}
+
+// call sends a request to the Alert API. Opsgenie processes the requests
+// asynchronously, so a successful response only means it was accepted.
+func (sink *OpsgenieNotificationSink) call(path string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, sink.APIURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "GenieKey "+sink.APIKey)
+	resp, err := sink.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("opsgenie responded with status %v: %v", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// opsgenieAlias returns the alias of the alert for a dedup key. Keys over the
+// length limit of Opsgenie are shortened and suffixed with their hash, so
+// that they stay distinct and the same alert is found when closing it.
+func opsgenieAlias(dedupKey string) string {
+	if utf8.RuneCountInString(dedupKey) <= opsgenieMaxAliasLength {
+		return dedupKey
+	}
+	sum := sha256.Sum256([]byte(dedupKey))
+	hash := hex.EncodeToString(sum[:])
+	return truncateText(dedupKey, opsgenieMaxAliasLength-len(hash)-1) + "-" + hash
+}
+
+// opsgeniePriority maps the severity to a priority from P1 (critical) to P5 (informational).
+func opsgeniePriority(severity Severity) string {
+	switch severity {
+	case Severity_Emergency, Severity_Alert:
+		return "P1"
+	case Severity_Critical:
+		return "P2"
+	case Severity_Error:
+		return "P3"
+	case Severity_Warning:
+		return "P4"
+	}
+	return "P5"
+}
+
+func init() {
+	registerSinkType("opsgenie", func(config sinkConfig, managers *sinkManagers) (NotificationSink, error) {
+		return &OpsgenieNotificationSink{
+			APIKey:   config.String("api_key"),
+			APIURL:   config.String("api_url"),
+			Source:   config.String("source"),
+			Tags:     config.Strings("tags"),
+			Severity: Severity(strings.ToLower(config.String("severity"))),
+		}, nil
+	})
+}
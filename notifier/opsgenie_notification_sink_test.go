@@ -0,0 +1,178 @@
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+type testOpsgenieRequest struct {
+	path  string
+	query string
+	body  map[string]interface{}
+}
+
+func TestOpsgenieNotificationSink(t *testing.T) {
+	requests := make(chan *testOpsgenieRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "GenieKey key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message":"Key format is not valid!"}`))
+			return
+		}
+		req := &testOpsgenieRequest{path: r.URL.EscapedPath(), query: r.URL.RawQuery}
+		if err := json.NewDecoder(r.Body).Decode(&req.body); err != nil {
+			t.Error(err)
+		}
+		requests <- req
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	sink := &OpsgenieNotificationSink{APIKey: "key", APIURL: server.URL + "/", Tags: []string{"backup"}}
+	longKey := strings.Repeat("backup/", 100)
+	if err := sink.Init(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		notification *Notification
+		wantPath     string
+		wantQuery    string
+		wantBody     map[string]interface{}
+		wantErr      bool
+	}{
+		{
+			name:         "create",
+			notification: &Notification{Title: "Backup failed", Body: "disk full", Severity: Severity_Critical, DedupKey: "backup"},
+			wantPath:     "/v2/alerts",
+			wantBody: map[string]interface{}{
+				"message":     "Backup failed",
+				"alias":       "backup",
+				"description": "disk full",
+				"priority":    "P2",
+				"source":      "notifier",
+			},
+		},
+		{
+			name:         "default severity",
+			notification: &Notification{Body: "disk full"},
+			wantPath:     "/v2/alerts",
+			wantBody: map[string]interface{}{
+				"message":     "disk full",
+				"description": "disk full",
+				"priority":    "P3",
+				"source":      "notifier",
+			},
+		},
+		{
+			name:         "close",
+			notification: &Notification{Body: "backup finished", Action: NotificationAction_Resolve, DedupKey: "backup/daily"},
+			wantPath:     "/v2/alerts/backup%2Fdaily/close",
+			wantQuery:    "identifierType=alias",
+			wantBody: map[string]interface{}{
+				"source": "notifier",
+				"note":   "backup finished",
+			},
+		},
+		{
+			name:         "create with a long dedup key",
+			notification: &Notification{Title: "Backup failed", Severity: Severity_Critical, DedupKey: longKey},
+			wantPath:     "/v2/alerts",
+			wantBody: map[string]interface{}{
+				"alias": opsgenieAlias(longKey),
+			},
+		},
+		{
+			// the alert has to be found by the alias it was created with
+			name:         "close with a long dedup key",
+			notification: &Notification{Action: NotificationAction_Resolve, DedupKey: longKey},
+			wantPath:     "/v2/alerts/" + url.PathEscape(opsgenieAlias(longKey)) + "/close",
+			wantQuery:    "identifierType=alias",
+		},
+		{
+			name:         "close without dedup key",
+			notification: &Notification{Body: "backup finished", Action: NotificationAction_Resolve},
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := sink.DeliverNotification(tt.notification)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DeliverNotification() = %v, want error: %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			req := <-requests
+			if req.path != tt.wantPath || req.query != tt.wantQuery {
+				t.Errorf("request to %v?%v, want %v?%v", req.path, req.query, tt.wantPath, tt.wantQuery)
+			}
+			for field, want := range tt.wantBody {
+				if req.body[field] != want {
+					t.Errorf("%v = %v, want %v", field, req.body[field], want)
+				}
+			}
+			if tags, _ := req.body["tags"].([]interface{}); tt.wantPath == "/v2/alerts" && (len(tags) != 1 || tags[0] != "backup") {
+				t.Errorf("tags = %v, want [backup]", req.body["tags"])
+			}
+		})
+	}
+
+	sink.APIKey = "wrong"
+	if err := sink.DeliverNotification(&Notification{Body: "disk full"}); err == nil {
+		t.Error("DeliverNotification() succeeded on a 401 response")
+	}
+}
+
+func TestOpsgenieAlias(t *testing.T) {
+	long := strings.Repeat("ä", 600)
+	tests := []struct {
+		name       string
+		dedupKey   string
+		wantPrefix string
+		wantLength int
+	}{
+		{"short", "backup/daily", "backup/daily", 12},
+		{"at the limit", strings.Repeat("a", 512), strings.Repeat("a", 512), 512},
+		// the prefix is followed by a dash and 64 hex digits of the hash
+		{"long", long, strings.Repeat("ä", 447) + "-", 512},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := opsgenieAlias(tt.dedupKey)
+			if !strings.HasPrefix(got, tt.wantPrefix) || utf8.RuneCountInString(got) != tt.wantLength {
+				t.Errorf("opsgenieAlias() = %q, want %v characters starting with %q", got, tt.wantLength, tt.wantPrefix)
+			}
+		})
+	}
+	// keys sharing the first 512 characters must not close each other's alerts
+	if opsgenieAlias(long+"a") == opsgenieAlias(long+"b") {
+		t.Error("long dedup keys with the same prefix have the same alias")
+	}
+}
+
+func TestOpsgeniePriority(t *testing.T) {
+	tests := []struct {
+		severity Severity
+		want     string
+	}{
+		{Severity_Emergency, "P1"},
+		{Severity_Alert, "P1"},
+		{Severity_Critical, "P2"},
+		{Severity_Error, "P3"},
+		{Severity_Warning, "P4"},
+		{Severity_Notice, "P5"},
+		{Severity_Debug, "P5"},
+	}
+	for _, tt := range tests {
+		if got := opsgeniePriority(tt.severity); got != tt.want {
+			t.Errorf("opsgeniePriority(%q) = %v, want %v", tt.severity, got, tt.want)
+		}
+	}
+}
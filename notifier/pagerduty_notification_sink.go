@@ -0,0 +1,140 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const pagerDutyDefaultAPIURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDutyNotificationSink triggers and resolves PagerDuty incidents with
+// the Events API v2. Notifications with the same dedup key are grouped in
+// the same incident, which a notification with the resolve action resolves.
+type PagerDutyNotificationSink struct {
+	RoutingKey string
+	Source     string
+	Severity   Severity // used for notifications without a severity
+	APIURL     string
+	client     *http.Client
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key,omitempty"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Timestamp     string            `json:"timestamp,omitempty"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+func (sink *PagerDutyNotificationSink) Init() error {
+	if sink.RoutingKey == "" {
+		return fmt.Errorf("routing_key is not set")
+	}
+	if sink.APIURL == "" {
+		sink.APIURL = pagerDutyDefaultAPIURL
+	}
+	if sink.Source == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "notifier"
+		}
+		sink.Source = hostname
+	}
+	if sink.Severity == "" {
+		sink.Severity = Severity_Error
+	}
+	if _, err := sink.Severity.SyslogLevel(); err != nil {
+		return err
+	}
+	sink.client = &http.Client{
+		Timeout: 10 * time.Second,
+	}
+	log.Printf("Successfully initialized %T", *sink)
+	return nil
+}
+
+func (sink *PagerDutyNotificationSink) ManagesIncidents() {}
+
+func (sink *PagerDutyNotificationSink) DeliverNotification(notification *Notification) error {
+	event := &pagerDutyEvent{
+		RoutingKey:  sink.RoutingKey,
+		EventAction: string(NotificationAction_Trigger),
+		DedupKey:    notification.DedupKey,
+	}
+	if notification.Action == NotificationAction_Resolve {
+		if notification.DedupKey == "" {
+			return fmt.Errorf("a dedup key is required to resolve an incident")
+		}
+		event.EventAction = string(NotificationAction_Resolve)
+	} else {
+		summary := notification.Title
+		if summary == "" {
+			summary = notification.Body
+		}
+		severity := notification.Severity
+		if severity == "" {
+			severity = sink.Severity
+		}
+		event.Payload = &pagerDutyPayload{
+			Summary:   truncateText(summary, 1024),
+			Source:    sink.Source,
+			Severity:  pagerDutySeverity(severity),
+			Timestamp: notification.Timestamp.Format(time.RFC3339),
+			CustomDetails: map[string]string{
+				"body": notification.Body,
+			},
+		}
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	resp, err := sink.client.Post(sink.APIURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("pagerduty responded with status %v: %v", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// pagerDutySeverity maps the severity to one of the four PagerDuty severities.
+func pagerDutySeverity(severity Severity) string {
+	switch severity {
+	case Severity_Emergency, Severity_Alert, Severity_Critical:
+		return "critical"
+	case Severity_Error:
+		return "error"
+	case Severity_Warning:
+		return "warning"
+	}
+	return "info"
+}
+
+func init() {
+	registerSinkType("pagerduty", func(config sinkConfig, managers *sinkManagers) (NotificationSink, error) {
+		return &PagerDutyNotificationSink{
+			RoutingKey: config.String("routing_key"),
+			Source:     config.String("source"),
+			Severity:   Severity(strings.ToLower(config.String("severity"))),
+			APIURL:     config.String("api_url"),
+		}, nil
+	})
+}
@@ -0,0 +1,123 @@
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPagerDutyNotificationSink(t *testing.T) {
+	events := make(chan *pagerDutyEvent, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event pagerDutyEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Error(err)
+		}
+		if event.RoutingKey != "R0UT1NG" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status":"invalid event"}`))
+			return
+		}
+		events <- &event
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	sink := &PagerDutyNotificationSink{RoutingKey: "R0UT1NG", Source: "backup-host", APIURL: server.URL}
+	if err := sink.Init(); err != nil {
+		t.Fatal(err)
+	}
+	timestamp := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		notification *Notification
+		wantAction   string
+		wantSummary  string
+		wantSeverity string
+		wantErr      bool
+	}{
+		{
+			name:         "trigger",
+			notification: &Notification{Title: "Backup failed", Body: "disk full", Severity: Severity_Critical, DedupKey: "backup", Timestamp: timestamp},
+			wantAction:   "trigger",
+			wantSummary:  "Backup failed",
+			wantSeverity: "critical",
+		},
+		{
+			name:         "default severity and body as summary",
+			notification: &Notification{Body: "disk full", Timestamp: timestamp},
+			wantAction:   "trigger",
+			wantSummary:  "disk full",
+			wantSeverity: "error",
+		},
+		{
+			name:         "resolve",
+			notification: &Notification{Body: "backup finished", Action: NotificationAction_Resolve, DedupKey: "backup"},
+			wantAction:   "resolve",
+		},
+		{
+			name:         "resolve without dedup key",
+			notification: &Notification{Body: "backup finished", Action: NotificationAction_Resolve},
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := sink.DeliverNotification(tt.notification)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DeliverNotification() = %v, want error: %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			event := <-events
+			if event.EventAction != tt.wantAction || event.DedupKey != tt.notification.DedupKey {
+				t.Errorf("event = %+v", event)
+			}
+			if tt.wantAction == "resolve" {
+				if event.Payload != nil {
+					t.Errorf("resolve event has a payload %+v", event.Payload)
+				}
+				return
+			}
+			if event.Payload == nil {
+				t.Fatal("trigger event has no payload")
+			}
+			if event.Payload.Summary != tt.wantSummary || event.Payload.Severity != tt.wantSeverity ||
+				event.Payload.Source != "backup-host" || event.Payload.Timestamp != "2021-10-01T12:00:00Z" {
+				t.Errorf("payload = %+v", event.Payload)
+			}
+		})
+	}
+
+	sink.RoutingKey = "wrong"
+	if err := sink.DeliverNotification(&Notification{Body: "disk full"}); err == nil {
+		t.Error("DeliverNotification() succeeded on a 400 response")
+	}
+}
+
+func TestPagerDutyNotificationSinkInit(t *testing.T) {
+	tests := []struct {
+		name    string
+		sink    PagerDutyNotificationSink
+		wantErr bool
+	}{
+		{"defaults", PagerDutyNotificationSink{RoutingKey: "R0UT1NG"}, false},
+		{"no routing key", PagerDutyNotificationSink{}, true},
+		{"invalid severity", PagerDutyNotificationSink{RoutingKey: "R0UT1NG", Severity: "urgent"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := tt.sink
+			err := sink.Init()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Init() = %v, want error: %v", err, tt.wantErr)
+			}
+			if err == nil && (sink.APIURL != pagerDutyDefaultAPIURL || sink.Source == "" || sink.Severity != Severity_Error) {
+				t.Errorf("sink = %+v, want the defaults", sink)
+			}
+		})
+	}
+}
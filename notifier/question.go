@@ -1,22 +1,222 @@
 package notifier
 
-import "time"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type QuestionKind string
 
 var (
-	QuestionKind_YesNo QuestionKind = "yesno"
-	QuestionKind_Text  QuestionKind = "text"
+	QuestionKind_YesNo  QuestionKind = "yesno"
+	QuestionKind_Text   QuestionKind = "text"
+	QuestionKind_Choice QuestionKind = "choice"
+)
+
+type TimeoutBehavior string
+
+var (
+	// TimeoutBehavior_Default answers a timed out question with its DefaultAnswer.
+	TimeoutBehavior_Default TimeoutBehavior = "default"
+	// TimeoutBehavior_Null answers a timed out question with a null value.
+	TimeoutBehavior_Null TimeoutBehavior = "null"
+	// TimeoutBehavior_Error makes the API respond with an error status when the question times out.
+	TimeoutBehavior_Error TimeoutBehavior = "error"
 )
 
+type QuestionOption struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
 type Question struct {
-	Text      string       `json:"text"`
-	Kind      QuestionKind `json:"kind"`
-	Timestamp time.Time    `json:"timestamp"`
+	ID        string           `json:"id"`
+	Text      string           `json:"text"`
+	Kind      QuestionKind     `json:"kind"`
+	Options   []QuestionOption `json:"options,omitempty"`
+	Timestamp time.Time        `json:"timestamp"`
+
+	DefaultAnswer   interface{}     `json:"defaultAnswer,omitempty"`
+	TimeoutBehavior TimeoutBehavior `json:"timeoutBehavior,omitempty"`
+
+	// Approvals is the number of distinct approvers who need to answer "yes"
+	// to a yesno question. A single "no" rejects the question.
+	Approvals int `json:"approvals,omitempty"`
+	// RequireAllSinks makes a yesno question require a "yes" from every sink.
+	RequireAllSinks bool `json:"requireAllSinks,omitempty"`
+}
+
+// IsVote reports whether the question needs answers from multiple approvers.
+func (q *Question) IsVote() bool {
+	return q.Approvals > 1 || q.RequireAllSinks
+}
+
+// Validate checks that the question is complete and that its default answer
+// is a valid answer for its kind.
+func (q *Question) Validate() error {
+	if q.Text == "" {
+		return fmt.Errorf("text is empty")
+	}
+	switch q.Kind {
+	case QuestionKind_YesNo, QuestionKind_Text:
+	case QuestionKind_Choice:
+		if len(q.Options) == 0 {
+			return fmt.Errorf("options are required for choice questions")
+		}
+	default:
+		return fmt.Errorf("unsupported question kind: %v", q.Kind)
+	}
+	if q.Approvals < 0 {
+		return fmt.Errorf("approvals must not be negative")
+	}
+	if q.IsVote() && q.Kind != QuestionKind_YesNo {
+		return fmt.Errorf("approvals and requireAllSinks are only supported for %v questions", QuestionKind_YesNo)
+	}
+	switch q.TimeoutBehavior {
+	case TimeoutBehavior_Default:
+		if q.DefaultAnswer == nil {
+			return fmt.Errorf("defaultAnswer is required when timeoutBehavior is %v", TimeoutBehavior_Default)
+		}
+		if _, ok := q.labelForValue(q.DefaultAnswer); !ok {
+			return fmt.Errorf("defaultAnswer %v is not a valid answer for a %v question", q.DefaultAnswer, q.Kind)
+		}
+	case TimeoutBehavior_Null, TimeoutBehavior_Error:
+	default:
+		return fmt.Errorf("unsupported timeoutBehavior: %v", q.TimeoutBehavior)
+	}
+	return nil
+}
+
+// TimeoutValue returns the value of the answer given when the question times out.
+func (q *Question) TimeoutValue() interface{} {
+	if q.TimeoutBehavior == TimeoutBehavior_Default {
+		return q.DefaultAnswer
+	}
+	return nil
+}
+
+// TimeoutLabel returns the text shown to the user when the question times out, for example "Timed out (No)".
+func (q *Question) TimeoutLabel() string {
+	if q.TimeoutBehavior == TimeoutBehavior_Default {
+		if label, ok := q.labelForValue(q.DefaultAnswer); ok {
+			return fmt.Sprintf("Timed out (%v)", label)
+		}
+	}
+	return "Timed out"
+}
+
+func (q *Question) labelForValue(value interface{}) (string, bool) {
+	switch q.Kind {
+	case QuestionKind_YesNo:
+		if v, ok := value.(bool); ok {
+			if v {
+				return "Yes", true
+			}
+			return "No", true
+		}
+	case QuestionKind_Text:
+		if v, ok := value.(string); ok {
+			return v, true
+		}
+	case QuestionKind_Choice:
+		for _, option := range q.Options {
+			if option.Value == value {
+				return option.Label, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Answerer identifies the person who answered a question.
+type Answerer struct {
+	Sink        string `json:"sink"`
+	ID          string `json:"id"`
+	Username    string `json:"username,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
 }
 
 type Answer struct {
 	TimedOut       bool          `json:"timedOut"`
 	AnwserDuration time.Duration `json:"answerDuration" swaggertype:"primitive,integer"`
 	Value          interface{}   `json:"value"`
+	AnsweredBy     *Answerer     `json:"answeredBy,omitempty"`
+	AnsweredAt     time.Time     `json:"answeredAt"`
+	// Votes lists the individual answers of a question with multiple approvers.
+	Votes []*Answer `json:"votes,omitempty"`
+}
+
+// parseReactionAnswer maps reactions like ✅ or ❌ to the answer of a yes/no question.
+func parseReactionAnswer(key string) (interface{}, string, bool) {
+	switch strings.TrimSuffix(key, "\ufe0f") {
+	case "✅", "✔", "👍":
+		return true, "Yes", true
+	case "❌", "✖", "👎":
+		return false, "No", true
+	}
+	return nil, "", false
+}
+
+// parseTextAnswer parses an answer typed in a chat: yes or no, the number or
+// label of an option, or the text itself depending on the kind of the question.
+func parseTextAnswer(question *Question, text string) (interface{}, string, bool) {
+	text = strings.TrimSpace(text)
+	switch question.Kind {
+	case QuestionKind_YesNo:
+		switch strings.ToLower(text) {
+		case "yes", "y":
+			return true, "Yes", true
+		case "no", "n":
+			return false, "No", true
+		}
+		return parseReactionAnswer(text)
+	case QuestionKind_Choice:
+		if i, err := strconv.Atoi(text); err == nil && i >= 1 && i <= len(question.Options) {
+			return question.Options[i-1].Value, question.Options[i-1].Label, true
+		}
+		for _, option := range question.Options {
+			if strings.EqualFold(option.Label, text) {
+				return option.Value, option.Label, true
+			}
+		}
+	case QuestionKind_Text:
+		if text != "" {
+			return text, text, true
+		}
+	}
+	return nil, "", false
+}
+
+// codedQuestionPrompt renders the question with instructions to answer it by
+// sending a message starting with the code.
+func codedQuestionPrompt(question *Question, code string) (string, error) {
+	switch question.Kind {
+	case QuestionKind_YesNo:
+		return fmt.Sprintf("[%v] %v\nReply with \"%v yes\" or \"%v no\".", code, question.Text, code, code), nil
+	case QuestionKind_Choice:
+		lines := []string{fmt.Sprintf("[%v] %v", code, question.Text)}
+		for i, option := range question.Options {
+			lines = append(lines, fmt.Sprintf("%v. %v", i+1, option.Label))
+		}
+		lines = append(lines, fmt.Sprintf("Reply with \"%v <option number>\".", code))
+		return strings.Join(lines, "\n"), nil
+	case QuestionKind_Text:
+		return fmt.Sprintf("[%v] %v\nReply with \"%v <your answer>\".", code, question.Text, code), nil
+	}
+	return "", fmt.Errorf("unsupported question kind: %v", question.Kind)
+}
+
+// parseCodedAnswer returns the answer from a message starting with the code
+// of a question, like "K7QX yes".
+func parseCodedAnswer(code string, text string) (string, bool) {
+	fields := strings.SplitN(strings.TrimSpace(text), " ", 2)
+	if !strings.EqualFold(strings.Trim(fields[0], "[]:,"), code) {
+		return "", false
+	}
+	if len(fields) < 2 {
+		return "", true
+	}
+	return strings.TrimSpace(fields[1]), true
 }
@@ -0,0 +1,99 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+var callbackHttpClient = &http.Client{
+	Timeout: 10 * time.Second,
+}
+
+// validateCallbackURL checks the callback URL of a question. Callbacks are
+// only accepted with a dedicated questions.callback_secret, since receivers
+// must know the key to verify the signatures.
+func validateCallbackURL(callbackURL string) error {
+	if viper.GetString("questions.callback_secret") == "" {
+		return fmt.Errorf("callbackUrl requires questions.callback_secret to be set")
+	}
+	u, err := url.Parse(callbackURL)
+	if err != nil {
+		return fmt.Errorf("invalid callbackUrl: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid callbackUrl: scheme must be http or https")
+	}
+	return nil
+}
+
+// signCallbackPayload computes the HMAC-SHA256 of the timestamp and payload
+// joined with a dot, so that receivers can reject replayed requests.
+func signCallbackPayload(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliverQuestionCallback POSTs the question response to the callback URL,
+// retrying with exponential backoff when the receiver is unavailable.
+func deliverQuestionCallback(callbackURL string, response *PostQuestionResponse) {
+	payload, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("failed to marshal callback payload for question %v: %v", response.ID, err)
+		return
+	}
+	secret := viper.GetString("questions.callback_secret")
+	if secret == "" {
+		log.Printf("not delivering callback for question %v: questions.callback_secret is not set", response.ID)
+		return
+	}
+	retries := viper.GetInt("questions.callback_retries")
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		err = postQuestionCallback(callbackURL, secret, payload)
+		if err == nil {
+			return
+		}
+		if attempt >= retries {
+			break
+		}
+		log.Printf("callback for question %v failed (attempt %v), retrying in %v: %v", response.ID, attempt+1, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	log.Printf("giving up on callback for question %v: %v", response.ID, err)
+}
+
+func postQuestionCallback(callbackURL string, secret string, payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, callbackURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Notifier")
+	req.Header.Set("X-Notifier-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Notifier-Signature", signCallbackPayload(secret, timestamp, payload))
+	resp, err := callbackHttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %v", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package notifier
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestSignCallbackPayload(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		payload   string
+		want      string
+	}{
+		{"payload", "secret", 1700000000, `{"id":"q1"}`, "sha256=3aee76d5df16b2c25dc8a25b09305c6012a8b51d8e692757aa277d1db5bd96ad"},
+		{"other secret", "other", 1700000000, `{"id":"q1"}`, "sha256=ee658d4c50f04c4920bf69488612c273eb777b9f184666e69b1e5a48292ed8f6"},
+		{"other timestamp", "secret", 1700000001, `{"id":"q1"}`, "sha256=59d5ccae594853e416305b5d3c1035fa37d92fcea5d9126256576ac1ea160561"},
+		{"empty payload", "secret", 1700000000, "", "sha256=4bc5f74d868b97888288889c5d9d65df02526f94c1592a79fdf4fe8b26e311e5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := signCallbackPayload(tt.secret, tt.timestamp, []byte(tt.payload)); got != tt.want {
+				t.Errorf("signCallbackPayload() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostQuestionCallback(t *testing.T) {
+	payload := []byte(`{"id":"q1","status":"answered"}`)
+	var status int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, err := strconv.ParseInt(r.Header.Get("X-Notifier-Timestamp"), 10, 64)
+		if err != nil {
+			t.Errorf("invalid timestamp header: %v", err)
+		}
+		if got, want := r.Header.Get("X-Notifier-Signature"), signCallbackPayload("secret", timestamp, body); got != want {
+			t.Errorf("signature = %v, want %v", got, want)
+		}
+		if string(body) != string(payload) {
+			t.Errorf("body = %s, want %s", body, payload)
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	status = http.StatusNoContent
+	if err := postQuestionCallback(server.URL, "secret", payload); err != nil {
+		t.Errorf("postQuestionCallback() = %v, want nil", err)
+	}
+	status = http.StatusServiceUnavailable
+	if err := postQuestionCallback(server.URL, "secret", payload); err == nil {
+		t.Error("postQuestionCallback() succeeded on a 503 response")
+	}
+}
+
+func TestValidateCallbackURL(t *testing.T) {
+	viper.Set("http.jwt_secret", "jwt secret")
+	defer viper.Set("http.jwt_secret", "")
+	tests := []struct {
+		name    string
+		secret  string
+		url     string
+		wantErr bool
+	}{
+		{"https", "secret", "https://example.com/callback", false},
+		{"http", "secret", "http://localhost:9000/callback", false},
+		{"other scheme", "secret", "ftp://example.com/callback", true},
+		{"invalid", "secret", "http://[::1", true},
+		{"without callback secret", "", "https://example.com/callback", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set("questions.callback_secret", tt.secret)
+			defer viper.Set("questions.callback_secret", "")
+			err := validateCallbackURL(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateCallbackURL(%q) = %v, want error: %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDeliverQuestionCallbackNeedsCallbackSecret(t *testing.T) {
+	// the JWT secret signs login tokens and must never be handed to callback receivers
+	viper.Set("http.jwt_secret", "jwt secret")
+	defer viper.Set("http.jwt_secret", "")
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+	deliverQuestionCallback(server.URL, &PostQuestionResponse{ID: "q1"})
+	if requests != 0 {
+		t.Errorf("the callback was delivered %v times without questions.callback_secret", requests)
+	}
+}
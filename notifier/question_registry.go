@@ -0,0 +1,178 @@
+package notifier
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+type QuestionStatus string
+
+var (
+	QuestionStatus_Pending   QuestionStatus = "pending"
+	QuestionStatus_Answered  QuestionStatus = "answered"
+	QuestionStatus_TimedOut  QuestionStatus = "timedOut"
+	QuestionStatus_Cancelled QuestionStatus = "cancelled"
+	QuestionStatus_Failed    QuestionStatus = "failed"
+	QuestionStatus_Expired   QuestionStatus = "expired"
+)
+
+// PendingQuestion tracks a question which has been dispatched to the sinks,
+// along with its outcome once one of them answers.
+type PendingQuestion struct {
+	Question *Question
+	// Username is the user who asked the question, the only one who can poll or cancel it.
+	Username string
+
+	mutex  sync.RWMutex
+	status QuestionStatus
+	answer *Answer
+	errors map[string]string
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Done returns a channel which is closed once the question is no longer pending.
+func (pq *PendingQuestion) Done() <-chan struct{} {
+	return pq.done
+}
+
+func (pq *PendingQuestion) Status() QuestionStatus {
+	pq.mutex.RLock()
+	defer pq.mutex.RUnlock()
+	return pq.status
+}
+
+// Response returns a snapshot of the question state in the format returned by the API.
+func (pq *PendingQuestion) Response() *PostQuestionResponse {
+	pq.mutex.RLock()
+	defer pq.mutex.RUnlock()
+	errorsMap := make(map[string]string, len(pq.errors))
+	for k, v := range pq.errors {
+		errorsMap[k] = v
+	}
+	return &PostQuestionResponse{
+		ID:     pq.Question.ID,
+		Status: pq.status,
+		Errors: errorsMap,
+		Answer: pq.answer,
+	}
+}
+
+// finish moves the question out of the pending state. It returns false if
+// the question has already been finished (for example cancelled).
+func (pq *PendingQuestion) finish(status QuestionStatus, answer *Answer, errorsMap map[string]string) bool {
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+	if pq.status != QuestionStatus_Pending {
+		return false
+	}
+	pq.status = status
+	pq.answer = answer
+	if errorsMap != nil {
+		pq.errors = errorsMap
+	}
+	close(pq.done)
+	pq.cancel()
+	return true
+}
+
+// QuestionRegistry keeps track of all the questions asked through the API,
+// so that they can be polled and cancelled by ID.
+type QuestionRegistry struct {
+	mutex     sync.RWMutex
+	questions map[string]*PendingQuestion
+	// Retention is how long finished questions are kept around for polling.
+	Retention time.Duration
+	// Store persists pending questions, so they can be expired after a restart.
+	Store *QuestionStore
+}
+
+func NewQuestionRegistry(retention time.Duration, store *QuestionStore) *QuestionRegistry {
+	return &QuestionRegistry{
+		questions: make(map[string]*PendingQuestion),
+		Retention: retention,
+		Store:     store,
+	}
+}
+
+// Add registers the question under its ID and persists it until it is
+// finished. The cancel function is called once the question is finished.
+func (r *QuestionRegistry) Add(question *Question, username string, deadline time.Time, callbackURL string, cancel context.CancelFunc) *PendingQuestion {
+	if err := r.Store.Add(question, username, deadline, callbackURL); err != nil {
+		log.Printf("failed to persist question %v: %v", question.ID, err)
+	}
+	pq := &PendingQuestion{
+		Question: question,
+		Username: username,
+		status:   QuestionStatus_Pending,
+		errors:   make(map[string]string),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	r.mutex.Lock()
+	r.questions[question.ID] = pq
+	r.mutex.Unlock()
+	go func() {
+		<-pq.done
+		if err := r.Store.Remove(question.ID); err != nil {
+			log.Printf("failed to remove question %v from store: %v", question.ID, err)
+		}
+		r.forgetAfterRetention(question.ID)
+	}()
+	return pq
+}
+
+// AddExpired registers a question left over from a previous run, which can
+// no longer be answered. If its deadline passed while the notifier was
+// stopped, it is reported as timed out instead.
+func (r *QuestionRegistry) AddExpired(question *Question, username string, deadline time.Time) *PendingQuestion {
+	pq := &PendingQuestion{
+		Question: question,
+		Username: username,
+		status:   QuestionStatus_Expired,
+		errors:   make(map[string]string),
+		cancel:   func() {},
+		done:     make(chan struct{}),
+	}
+	if !deadline.IsZero() && time.Now().After(deadline) {
+		pq.status = QuestionStatus_TimedOut
+		pq.answer = &Answer{
+			Value:          question.TimeoutValue(),
+			TimedOut:       true,
+			AnwserDuration: deadline.Sub(question.Timestamp),
+			AnsweredAt:     deadline,
+		}
+	}
+	close(pq.done)
+	r.mutex.Lock()
+	r.questions[question.ID] = pq
+	r.mutex.Unlock()
+	r.forgetAfterRetention(question.ID)
+	return pq
+}
+
+func (r *QuestionRegistry) forgetAfterRetention(id string) {
+	time.AfterFunc(r.Retention, func() {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		delete(r.questions, id)
+	})
+}
+
+func (r *QuestionRegistry) Get(id string) *PendingQuestion {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.questions[id]
+}
+
+// Cancel stops the question with the given ID. It returns false if there is
+// no such question or if it has already finished.
+func (r *QuestionRegistry) Cancel(id string) bool {
+	pq := r.Get(id)
+	if pq == nil {
+		return false
+	}
+	return pq.finish(QuestionStatus_Cancelled, nil, nil)
+}
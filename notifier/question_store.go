@@ -0,0 +1,155 @@
+package notifier
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// questionExpiredLabel replaces the answer buttons of the questions left over from a previous run.
+const questionExpiredLabel = "Expired: the notifier was restarted"
+
+// StoredQuestionMessage identifies a message which a sink has sent to ask a
+// question. Telegram uses the numeric ChatID and MessageID, the other sinks
+// use Channel and Message.
+type StoredQuestionMessage struct {
+	SinkID    string `json:"sinkId"`
+	ChatID    int64  `json:"chatId,omitempty"`
+	MessageID int    `json:"messageId,omitempty"`
+	Channel   string `json:"channel,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// StoredQuestion is the persisted state of a pending question.
+type StoredQuestion struct {
+	Question    *Question                `json:"question"`
+	Username    string                   `json:"username"`
+	Deadline    time.Time                `json:"deadline"`
+	CallbackURL string                   `json:"callbackUrl,omitempty"`
+	Messages    []*StoredQuestionMessage `json:"messages"`
+}
+
+// QuestionStore persists pending questions to a JSON file, so that the
+// messages sent by the sinks can be cleaned up after a restart.
+// A nil *QuestionStore is valid and does not persist anything.
+type QuestionStore struct {
+	path      string
+	mutex     sync.Mutex
+	questions map[string]*StoredQuestion
+}
+
+func NewQuestionStore(path string) *QuestionStore {
+	return &QuestionStore{
+		path:      path,
+		questions: make(map[string]*StoredQuestion),
+	}
+}
+
+// Load reads the questions persisted by a previous run. A missing file is not an error.
+func (store *QuestionStore) Load() ([]*StoredQuestion, error) {
+	if store == nil {
+		return nil, nil
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	data, err := os.ReadFile(store.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read question store %v: %w", store.path, err)
+	}
+	questions := make(map[string]*StoredQuestion)
+	if err := json.Unmarshal(data, &questions); err != nil {
+		return nil, fmt.Errorf("failed to parse question store %v: %w", store.path, err)
+	}
+	store.questions = questions
+	loaded := make([]*StoredQuestion, 0, len(questions))
+	for _, q := range questions {
+		loaded = append(loaded, q)
+	}
+	return loaded, nil
+}
+
+func (store *QuestionStore) Add(question *Question, username string, deadline time.Time, callbackURL string) error {
+	if store == nil {
+		return nil
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.questions[question.ID] = &StoredQuestion{
+		Question:    question,
+		Username:    username,
+		Deadline:    deadline,
+		CallbackURL: callbackURL,
+		Messages:    []*StoredQuestionMessage{},
+	}
+	return store.save()
+}
+
+// AddMessage records a message sent by a sink for the question with the given ID.
+func (store *QuestionStore) AddMessage(questionID string, message *StoredQuestionMessage) error {
+	if store == nil {
+		return nil
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	q, ok := store.questions[questionID]
+	if !ok {
+		return nil
+	}
+	q.Messages = append(q.Messages, message)
+	return store.save()
+}
+
+// recordMessage is AddMessage for the sinks, a failure is only logged since
+// the question can still be answered.
+func (store *QuestionStore) recordMessage(questionID string, message *StoredQuestionMessage) {
+	if err := store.AddMessage(questionID, message); err != nil {
+		log.Printf("failed to persist question message: %v", err)
+	}
+}
+
+func (store *QuestionStore) Remove(questionID string) error {
+	if store == nil {
+		return nil
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if _, ok := store.questions[questionID]; !ok {
+		return nil
+	}
+	delete(store.questions, questionID)
+	return store.save()
+}
+
+// save writes the store to a temporary file and renames it over the old one,
+// so a crash never leaves a truncated store behind.
+func (store *QuestionStore) save() error {
+	data, err := json.MarshalIndent(store.questions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal question store: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(store.path), filepath.Base(store.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to write question store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write question store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write question store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), store.path); err != nil {
+		return fmt.Errorf("failed to write question store: %w", err)
+	}
+	return nil
+}
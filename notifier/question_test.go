@@ -0,0 +1,103 @@
+package notifier
+
+import "testing"
+
+func TestQuestionValidate(t *testing.T) {
+	options := []QuestionOption{{Label: "First", Value: "first"}, {Label: "Second", Value: "second"}}
+	tests := []struct {
+		name     string
+		question Question
+		wantErr  bool
+	}{
+		{"yesno", Question{Text: "Deploy?", Kind: QuestionKind_YesNo, TimeoutBehavior: TimeoutBehavior_Null}, false},
+		{"text", Question{Text: "Why?", Kind: QuestionKind_Text, TimeoutBehavior: TimeoutBehavior_Error}, false},
+		{"choice", Question{Text: "Which?", Kind: QuestionKind_Choice, Options: options, TimeoutBehavior: TimeoutBehavior_Null}, false},
+		{"empty text", Question{Kind: QuestionKind_YesNo, TimeoutBehavior: TimeoutBehavior_Null}, true},
+		{"unknown kind", Question{Text: "Deploy?", Kind: "maybe", TimeoutBehavior: TimeoutBehavior_Null}, true},
+		{"choice without options", Question{Text: "Which?", Kind: QuestionKind_Choice, TimeoutBehavior: TimeoutBehavior_Null}, true},
+		{"negative approvals", Question{Text: "Deploy?", Kind: QuestionKind_YesNo, Approvals: -1, TimeoutBehavior: TimeoutBehavior_Null}, true},
+		{"yesno vote", Question{Text: "Deploy?", Kind: QuestionKind_YesNo, Approvals: 2, TimeoutBehavior: TimeoutBehavior_Null}, false},
+		{"text vote", Question{Text: "Why?", Kind: QuestionKind_Text, Approvals: 2, TimeoutBehavior: TimeoutBehavior_Null}, true},
+		{"choice requiring all sinks", Question{Text: "Which?", Kind: QuestionKind_Choice, Options: options, RequireAllSinks: true, TimeoutBehavior: TimeoutBehavior_Null}, true},
+		{"yesno default", Question{Text: "Deploy?", Kind: QuestionKind_YesNo, TimeoutBehavior: TimeoutBehavior_Default, DefaultAnswer: false}, false},
+		{"missing default", Question{Text: "Deploy?", Kind: QuestionKind_YesNo, TimeoutBehavior: TimeoutBehavior_Default}, true},
+		{"yesno string default", Question{Text: "Deploy?", Kind: QuestionKind_YesNo, TimeoutBehavior: TimeoutBehavior_Default, DefaultAnswer: "no"}, true},
+		{"choice default", Question{Text: "Which?", Kind: QuestionKind_Choice, Options: options, TimeoutBehavior: TimeoutBehavior_Default, DefaultAnswer: "second"}, false},
+		{"choice unknown default", Question{Text: "Which?", Kind: QuestionKind_Choice, Options: options, TimeoutBehavior: TimeoutBehavior_Default, DefaultAnswer: "third"}, true},
+		{"text default", Question{Text: "Why?", Kind: QuestionKind_Text, TimeoutBehavior: TimeoutBehavior_Default, DefaultAnswer: "no reason"}, false},
+		{"unknown timeout behavior", Question{Text: "Deploy?", Kind: QuestionKind_YesNo, TimeoutBehavior: "retry"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.question.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseTextAnswer(t *testing.T) {
+	yesno := &Question{Kind: QuestionKind_YesNo}
+	choice := &Question{Kind: QuestionKind_Choice, Options: []QuestionOption{{Label: "First", Value: "first"}, {Label: "Second", Value: "second"}}}
+	text := &Question{Kind: QuestionKind_Text}
+	tests := []struct {
+		name      string
+		question  *Question
+		text      string
+		wantValue interface{}
+		wantLabel string
+		wantOK    bool
+	}{
+		{"yes", yesno, "yes", true, "Yes", true},
+		{"y with spaces", yesno, "  Y ", true, "Yes", true},
+		{"no", yesno, "No", false, "No", true},
+		{"reaction", yesno, "👍", true, "Yes", true},
+		{"reaction with variation selector", yesno, "✔️", true, "Yes", true},
+		{"negative reaction", yesno, "❌", false, "No", true},
+		{"unknown yesno", yesno, "maybe", nil, "", false},
+		{"option number", choice, "2", "second", "Second", true},
+		{"option label", choice, "first", "first", "First", true},
+		{"option number out of range", choice, "3", nil, "", false},
+		{"option number zero", choice, "0", nil, "", false},
+		{"unknown option", choice, "third", nil, "", false},
+		{"text", text, " because ", "because", "because", true},
+		{"empty text", text, "  ", nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, label, ok := parseTextAnswer(tt.question, tt.text)
+			if value != tt.wantValue || label != tt.wantLabel || ok != tt.wantOK {
+				t.Errorf("parseTextAnswer(%q) = %v, %q, %v, want %v, %q, %v", tt.text, value, label, ok, tt.wantValue, tt.wantLabel, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseCodedAnswer(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		want   string
+		wantOK bool
+	}{
+		{"answer", "K7QX yes", "yes", true},
+		{"lowercase code", "k7qx no", "no", true},
+		{"bracketed code", "[K7QX] 2", "2", true},
+		{"code with colon", "K7QX: because it is late", "because it is late", true},
+		{"surrounding spaces", "  K7QX   yes  ", "yes", true},
+		{"code only", "K7QX", "", true},
+		{"other code", "A1B2 yes", "", false},
+		{"code not first", "yes K7QX", "", false},
+		{"longer word", "K7QXZ yes", "", false},
+		{"empty", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseCodedAnswer("K7QX", tt.text)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("parseCodedAnswer(%q) = %q, %v, want %q, %v", tt.text, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// NotificationSinkWithVotes is implemented by sinks which can collect
+// answers from more than one person for the same question.
+type NotificationSinkWithVotes interface {
+	NotificationSinkWithQuestions
+	// CollectVotes asks the question and sends every vote to the votes channel
+	// until ctx is done. Every vote must carry AnsweredBy.
+	CollectVotes(ctx context.Context, question *Question, votes chan<- *Answer) error
+}
+
+// voteTally counts the votes for a question with multiple approvers.
+type voteTally struct {
+	question *Question
+	sinks    []string
+	votes    []*Answer
+	voters   map[string]bool
+	yes      int
+	yesSinks map[string]bool
+}
+
+func newVoteTally(question *Question, sinks []string) *voteTally {
+	return &voteTally{
+		question: question,
+		sinks:    sinks,
+		voters:   make(map[string]bool),
+		yesSinks: make(map[string]bool),
+	}
+}
+
+// add records a vote received from the sink with the ID. Repeated votes from
+// the same person are ignored. It returns true once the question is decided,
+// along with the outcome.
+func (t *voteTally) add(sinkID string, vote *Answer) (decided bool, approved bool) {
+	voterKey := sinkID
+	if vote.AnsweredBy != nil {
+		voterKey = fmt.Sprintf("%v:%v", vote.AnsweredBy.Sink, vote.AnsweredBy.ID)
+	}
+	if t.voters[voterKey] {
+		return false, false
+	}
+	t.voters[voterKey] = true
+	t.votes = append(t.votes, vote)
+
+	if vote.Value != true {
+		return true, false
+	}
+	t.yes++
+	t.yesSinks[sinkID] = true
+	if t.yes < t.question.Approvals {
+		return false, false
+	}
+	if t.question.RequireAllSinks {
+		for _, sink := range t.sinks {
+			if !t.yesSinks[sink] {
+				return false, false
+			}
+		}
+	}
+	return true, true
+}
+
+// shortfall describes the approvals missing for the question to be approved.
+func (t *voteTally) shortfall() string {
+	reasons := []string{}
+	if t.yes < t.question.Approvals {
+		reasons = append(reasons, fmt.Sprintf("not enough approvers answered (%v of %v)", t.yes, t.question.Approvals))
+	}
+	if t.question.RequireAllSinks {
+		missing := []string{}
+		for _, sink := range t.sinks {
+			if !t.yesSinks[sink] {
+				missing = append(missing, sink)
+			}
+		}
+		if len(missing) > 0 {
+			reasons = append(reasons, fmt.Sprintf("no approval from the sinks %v", strings.Join(missing, ", ")))
+		}
+	}
+	return strings.Join(reasons, "; ")
+}
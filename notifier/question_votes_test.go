@@ -0,0 +1,140 @@
+package notifier
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestVoteTallyAdd(t *testing.T) {
+	type vote struct {
+		sink  string
+		user  string
+		value interface{}
+	}
+	type outcome struct {
+		decided  bool
+		approved bool
+	}
+	tests := []struct {
+		name     string
+		question Question
+		sinks    []string
+		votes    []vote
+		want     []outcome
+	}{
+		{
+			name:     "two approvals",
+			question: Question{Kind: QuestionKind_YesNo, Approvals: 2},
+			sinks:    []string{"telegram", "slack"},
+			votes:    []vote{{"telegram", "alice", true}, {"slack", "bob", true}},
+			want:     []outcome{{false, false}, {true, true}},
+		},
+		{
+			name:     "repeated vote is ignored",
+			question: Question{Kind: QuestionKind_YesNo, Approvals: 2},
+			sinks:    []string{"telegram"},
+			votes:    []vote{{"telegram", "alice", true}, {"telegram", "alice", true}, {"telegram", "bob", true}},
+			want:     []outcome{{false, false}, {false, false}, {true, true}},
+		},
+		{
+			name:     "same user id on different sinks",
+			question: Question{Kind: QuestionKind_YesNo, Approvals: 2},
+			sinks:    []string{"telegram", "slack"},
+			votes:    []vote{{"telegram", "1", true}, {"slack", "1", true}},
+			want:     []outcome{{false, false}, {true, true}},
+		},
+		{
+			name:     "no rejects",
+			question: Question{Kind: QuestionKind_YesNo, Approvals: 3},
+			sinks:    []string{"telegram"},
+			votes:    []vote{{"telegram", "alice", true}, {"telegram", "bob", false}},
+			want:     []outcome{{false, false}, {true, false}},
+		},
+		{
+			name:     "all sinks",
+			question: Question{Kind: QuestionKind_YesNo, RequireAllSinks: true},
+			sinks:    []string{"telegram", "slack"},
+			votes:    []vote{{"telegram", "alice", true}, {"telegram", "bob", true}, {"slack", "carol", true}},
+			want:     []outcome{{false, false}, {false, false}, {true, true}},
+		},
+		{
+			name:     "all sinks of the same type",
+			question: Question{Kind: QuestionKind_YesNo, RequireAllSinks: true},
+			sinks:    []string{"telegram#0", "telegram#1"},
+			votes:    []vote{{"telegram#0", "alice", true}, {"telegram#0", "bob", true}, {"telegram#1", "carol", true}},
+			want:     []outcome{{false, false}, {false, false}, {true, true}},
+		},
+		{
+			name:     "all sinks and approvals",
+			question: Question{Kind: QuestionKind_YesNo, RequireAllSinks: true, Approvals: 3},
+			sinks:    []string{"telegram", "slack"},
+			votes:    []vote{{"telegram", "alice", true}, {"slack", "bob", true}, {"slack", "carol", true}},
+			want:     []outcome{{false, false}, {false, false}, {true, true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tally := newVoteTally(&tt.question, tt.sinks)
+			for i, v := range tt.votes {
+				decided, approved := tally.add(v.sink, &Answer{
+					Value:      v.value,
+					AnsweredBy: &Answerer{Sink: v.sink, ID: v.user},
+				})
+				if got := (outcome{decided, approved}); got != tt.want[i] {
+					t.Errorf("vote %v: add() = %+v, want %+v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestVoteTallyAddWithoutAnswerer(t *testing.T) {
+	tally := newVoteTally(&Question{Kind: QuestionKind_YesNo, Approvals: 2}, []string{"webhook"})
+	// votes without AnsweredBy are counted once per sink
+	tally.add("webhook", &Answer{Value: true})
+	if decided, _ := tally.add("webhook", &Answer{Value: true}); decided {
+		t.Error("the second anonymous vote of a sink was counted")
+	}
+	if len(tally.votes) != 1 {
+		t.Errorf("recorded %v votes, want 1", len(tally.votes))
+	}
+}
+
+func TestVoteTallyShortfall(t *testing.T) {
+	tests := []struct {
+		name     string
+		question Question
+		votes    []string // IDs of the sinks the yes votes came from
+		want     string
+	}{
+		{
+			name:     "approvals",
+			question: Question{Kind: QuestionKind_YesNo, Approvals: 3},
+			votes:    []string{"telegram#0"},
+			want:     "not enough approvers answered (1 of 3)",
+		},
+		{
+			name:     "all sinks",
+			question: Question{Kind: QuestionKind_YesNo, RequireAllSinks: true},
+			votes:    []string{"telegram#0"},
+			want:     "no approval from the sinks telegram#1, slack#2",
+		},
+		{
+			name:     "all sinks and approvals",
+			question: Question{Kind: QuestionKind_YesNo, RequireAllSinks: true, Approvals: 4},
+			votes:    []string{"telegram#0", "slack#2"},
+			want:     "not enough approvers answered (2 of 4); no approval from the sinks telegram#1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tally := newVoteTally(&tt.question, []string{"telegram#0", "telegram#1", "slack#2"})
+			for i, sinkID := range tt.votes {
+				tally.add(sinkID, &Answer{Value: true, AnsweredBy: &Answerer{Sink: "test", ID: fmt.Sprint(i)}})
+			}
+			if got := tally.shortfall(); got != tt.want {
+				t.Errorf("shortfall() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
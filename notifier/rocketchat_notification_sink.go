@@ -0,0 +1,58 @@
+package notifier
+
+import (
+	"log"
+	"time"
+)
+
+// RocketChatNotificationSink posts notifications as message attachments to a
+// Rocket.Chat incoming webhook integration.
+type RocketChatNotificationSink struct {
+	incomingWebhook
+	Channel string
+	Alias   string
+	Avatar  string
+}
+
+func (sink *RocketChatNotificationSink) Init() error {
+	if err := sink.incomingWebhook.init(); err != nil {
+		return err
+	}
+	log.Printf("Successfully initialized %T", *sink)
+	return nil
+}
+
+func (sink *RocketChatNotificationSink) DeliverNotification(notification *Notification) error {
+	payload := map[string]interface{}{
+		"attachments": []interface{}{
+			map[string]interface{}{
+				"color": severityColor(notification.Severity),
+				"title": notification.Title,
+				"text":  notification.Body,
+				"ts":    notification.Timestamp.Format(time.RFC3339),
+			},
+		},
+	}
+	// empty values would override the defaults of the integration
+	if sink.Channel != "" {
+		payload["channel"] = sink.Channel
+	}
+	if sink.Alias != "" {
+		payload["alias"] = sink.Alias
+	}
+	if sink.Avatar != "" {
+		payload["avatar"] = sink.Avatar
+	}
+	return sink.post(payload)
+}
+
+func init() {
+	registerSinkType("rocketchat", func(config sinkConfig, managers *sinkManagers) (NotificationSink, error) {
+		return &RocketChatNotificationSink{
+			incomingWebhook: incomingWebhook{WebhookURL: config.String("webhook_url")},
+			Channel:         config.String("channel"),
+			Alias:           config.String("alias"),
+			Avatar:          config.String("avatar"),
+		}, nil
+	})
+}
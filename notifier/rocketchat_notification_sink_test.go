@@ -0,0 +1,40 @@
+package notifier
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRocketChatNotificationSink(t *testing.T) {
+	server, payloads := newTestIncomingWebhook(t, http.StatusOK)
+	sink := &RocketChatNotificationSink{
+		incomingWebhook: incomingWebhook{WebhookURL: server.URL},
+		Channel:         "#alerts",
+		Alias:           "Notifier",
+	}
+	if err := sink.Init(); err != nil {
+		t.Fatal(err)
+	}
+	timestamp := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	if err := sink.DeliverNotification(&Notification{Title: "Backup", Body: "finished", Timestamp: timestamp}); err != nil {
+		t.Fatal(err)
+	}
+	payload := <-payloads
+	if payload["channel"] != "#alerts" || payload["alias"] != "Notifier" {
+		t.Errorf("payload = %v", payload)
+	}
+	if _, ok := payload["avatar"]; ok {
+		t.Error("an unset avatar overrides the default of the integration")
+	}
+	attachment := firstAttachment(t, payload)
+	if attachment["title"] != "Backup" || attachment["text"] != "finished" || attachment["ts"] != "2021-10-01T12:00:00Z" {
+		t.Errorf("attachment = %v", attachment)
+	}
+
+	server, _ = newTestIncomingWebhook(t, http.StatusBadRequest)
+	sink.WebhookURL = server.URL
+	if err := sink.DeliverNotification(&Notification{Body: "again"}); err == nil {
+		t.Error("DeliverNotification() succeeded on a 400 response")
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/spf13/viper"
@@ -18,14 +21,37 @@ func Run() {
 
 	viper.SetDefault("http.addr", ":8080")
 	viper.SetDefault("general.date_format", "2006-01-02 15:04:05")
+	viper.SetDefault("questions.retention", time.Hour)
+	viper.SetDefault("questions.callback_retries", 5)
 
 	err := viper.ReadInConfig()
 	if err != nil {
 		log.Fatalf("Fatal error while reading config file: %v", err)
 	}
 
-	tgManager := NewTelegramManager()
-	sinks, err := sinksFromConfig(tgManager)
+	// an empty store_path disables the store
+	viper.SetDefault("questions.store_path", filepath.Join(filepath.Dir(viper.ConfigFileUsed()), "notifier-questions.json"))
+	var questionStore *QuestionStore
+	if viper.GetString("questions.store_path") != "" {
+		questionStore = NewQuestionStore(viper.GetString("questions.store_path"))
+	}
+	storedQuestions, err := questionStore.Load()
+	if err != nil {
+		log.Fatalf("Fatal error while loading questions: %v", err)
+	}
+
+	managers := &sinkManagers{
+		Telegram:      NewTelegramManager(),
+		QuestionStore: questionStore,
+		AnswerLinks:   NewAnswerLinkManager(),
+		Slack:         NewSlackManager(),
+		Discord:       NewDiscordManager(),
+		Matrix:        NewMatrixManager(),
+		Signal:        NewSignalManager(),
+		IRC:           NewIRCManager(),
+		XMPP:          NewXMPPManager(),
+	}
+	sinks, err := sinksFromConfig(managers)
 	if err != nil {
 		log.Fatalf("Fatal error in config file: %v", err)
 	}
@@ -36,12 +62,46 @@ func Run() {
 	if viper.GetString("http.jwt_secret") == "" {
 		log.Fatalf("Fatal error in config file: jwt_secret is not defined")
 	}
-	hs := NewHttpServer(sinks, users)
+	questions := NewQuestionRegistry(viper.GetDuration("questions.retention"), questionStore)
+	expireStoredQuestions(sinks, questions, storedQuestions)
+	hs := NewHttpServer(sinks, users, questions, managers.AnswerLinks, managers.Slack)
 	hs.Start(viper.GetString("http.addr"))
 }
 
-func sinksFromConfig(tgManager *TelegramManager) ([]NotificationSink, error) {
-	sinks := []NotificationSink{}
+// expireStoredQuestions marks the questions which were pending when the
+// notifier was stopped as expired, or timed out if their deadline has passed
+// since, and updates the messages sent for them.
+func expireStoredQuestions(sinks []*configuredSink, questions *QuestionRegistry, storedQuestions []*StoredQuestion) {
+	sinksByID := make(map[string]NotificationSinkWithPersistentQuestions)
+	for _, sink := range sinks {
+		if s, ok := sink.Sink.(NotificationSinkWithPersistentQuestions); ok {
+			sinksByID[s.SinkID()] = s
+		}
+	}
+	for _, stored := range storedQuestions {
+		for _, message := range stored.Messages {
+			sink, ok := sinksByID[message.SinkID]
+			if !ok {
+				log.Printf("no sink %v for expired question %v", message.SinkID, stored.Question.ID)
+				continue
+			}
+			if err := sink.ExpireQuestion(stored.Question, message); err != nil {
+				log.Printf("failed to expire question %v in sink %v: %v", stored.Question.ID, message.SinkID, err)
+			}
+		}
+		pq := questions.AddExpired(stored.Question, stored.Username, stored.Deadline)
+		if err := questions.Store.Remove(stored.Question.ID); err != nil {
+			log.Printf("failed to remove question %v from store: %v", stored.Question.ID, err)
+		}
+		if stored.CallbackURL != "" {
+			go deliverQuestionCallback(stored.CallbackURL, pq.Response())
+		}
+	}
+}
+
+func sinksFromConfig(managers *sinkManagers) ([]*configuredSink, error) {
+	sinks := []*configuredSink{}
+	sinkIDs := make(map[string]bool)
 	sinksRaw := viper.Get("sinks")
 	if sinksRaw == nil {
 		return nil, fmt.Errorf("no sinks defined in config file")
@@ -50,45 +110,29 @@ func sinksFromConfig(tgManager *TelegramManager) ([]NotificationSink, error) {
 	if sinksRaw, ok := sinksRaw.([]interface{}); ok {
 		for i, sink := range sinksRaw {
 			if sink, ok := sink.(map[interface{}]interface{}); ok {
-				switch sink["type"] {
-				case "telegram":
-					s := &TelegramNotificationSink{
-						TelegramManager: tgManager,
-						BotToken:        sink["bot_token"].(string),
-					}
-					fmt.Sscanf(fmt.Sprintf("%v", sink["chat_id"]), "%v", &s.ChatID)
-					if err := s.Init(); err != nil {
-						return nil, fmt.Errorf("error initializing telegram sink #%v: %v", i, err)
-					}
-					sinks = append(sinks, s)
-				case "email":
-					to := []string{}
-					if sinkTo, ok := sink["to"].([]interface{}); ok {
-						for _, t := range sinkTo {
-							to = append(to, t.(string))
-						}
-					} else {
-						to = append(to, sink["to"].(string))
-					}
-					var startTLS bool
-					if sink["starttls"] != nil {
-						startTLS = sink["starttls"].(bool)
-					}
-					s := &EmailNotificationSink{
-						From:         sink["from"].(string),
-						To:           to,
-						SMTPAddress:  sink["smtp_address"].(string),
-						SMTPUsername: sink["smtp_username"].(string),
-						SMTPPassword: sink["smtp_password"].(string),
-						StartTLS:     startTLS,
-					}
-					if err := s.Init(); err != nil {
-						return nil, fmt.Errorf("error initializing email sink #%v: %v", i, err)
-					}
-					sinks = append(sinks, s)
-				default:
-					return nil, fmt.Errorf("unknown sink type: %s", sink["type"])
+				config := sinkConfig(sink)
+				sinkType := config.String("type")
+				factory, ok := sinkFactories[sinkType]
+				if !ok {
+					return nil, fmt.Errorf("unknown sink type: %s (available types: %v)", sinkType, strings.Join(sinkTypes(), ", "))
+				}
+				// the position in the list is stable as long as the config does not change
+				id := config.String("name")
+				if id == "" {
+					id = fmt.Sprintf("%v#%v", sinkType, i)
+				}
+				if sinkIDs[id] {
+					return nil, fmt.Errorf("duplicate sink name: %v", id)
+				}
+				sinkIDs[id] = true
+				s, err := factory(config, managers)
+				if err != nil {
+					return nil, fmt.Errorf("error in %v sink #%v: %v", sinkType, i, err)
+				}
+				if err := s.Init(); err != nil {
+					return nil, fmt.Errorf("error initializing %v sink #%v: %v", sinkType, i, err)
 				}
+				sinks = append(sinks, &configuredSink{ID: id, Sink: s})
 			}
 		}
 	} else {
@@ -0,0 +1,111 @@
+package notifier
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestSinksFromConfigIDs(t *testing.T) {
+	webhook := func(name string) map[interface{}]interface{} {
+		config := map[interface{}]interface{}{"type": "webhook", "url": "https://example.com/hook"}
+		if name != "" {
+			config["name"] = name
+		}
+		return config
+	}
+	tests := []struct {
+		name    string
+		sinks   []interface{}
+		want    []string
+		wantErr bool
+	}{
+		{"positions", []interface{}{webhook(""), webhook("")}, []string{"webhook#0", "webhook#1"}, false},
+		{"names", []interface{}{webhook("alerts"), webhook("")}, []string{"alerts", "webhook#1"}, false},
+		{"duplicate names", []interface{}{webhook("alerts"), webhook("alerts")}, nil, true},
+		{"name of another position", []interface{}{webhook(""), webhook("webhook#0")}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set("sinks", tt.sinks)
+			defer viper.Set("sinks", nil)
+			sinks, err := sinksFromConfig(&sinkManagers{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sinksFromConfig() = %v, want error: %v", err, tt.wantErr)
+			}
+			if len(sinks) != len(tt.want) {
+				t.Fatalf("got %v sinks, want %v", len(sinks), len(tt.want))
+			}
+			for i, sink := range sinks {
+				if sink.ID != tt.want[i] {
+					t.Errorf("sink %v has the ID %v, want %v", i, sink.ID, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// testPersistentSink records the question messages it is asked to expire.
+type testPersistentSink struct {
+	*testQuestionSink
+	id      string
+	expired []*StoredQuestionMessage
+}
+
+func (sink *testPersistentSink) SinkID() string {
+	return sink.id
+}
+
+func (sink *testPersistentSink) ExpireQuestion(question *Question, message *StoredQuestionMessage) error {
+	sink.expired = append(sink.expired, message)
+	return nil
+}
+
+func TestExpireStoredQuestions(t *testing.T) {
+	telegram := &testPersistentSink{id: "telegram:1:2"}
+	slack := &testPersistentSink{id: "slack:U1:C1"}
+	sinks := []*configuredSink{
+		{ID: "telegram#0", Sink: telegram},
+		{ID: "slack#1", Sink: slack},
+	}
+	questions := NewQuestionRegistry(time.Minute, nil)
+	expireStoredQuestions(sinks, questions, []*StoredQuestion{
+		{
+			Question: &Question{ID: "pending", Kind: QuestionKind_YesNo, Text: "Deploy?", Timestamp: time.Now()},
+			Username: "alice",
+			Deadline: time.Now().Add(time.Hour),
+			Messages: []*StoredQuestionMessage{
+				{SinkID: "telegram:1:2", ChatID: 2, MessageID: 3},
+				{SinkID: "slack:U1:C1", Channel: "C1", Message: "1.2"},
+				// sinks removed from the config are skipped
+				{SinkID: "discord:3"},
+			},
+		},
+		{
+			Question: &Question{ID: "past deadline", Kind: QuestionKind_YesNo, Text: "Deploy?", Timestamp: time.Now().Add(-time.Hour)},
+			Username: "alice",
+			Deadline: time.Now().Add(-time.Minute),
+			Messages: []*StoredQuestionMessage{{SinkID: "slack:U1:C1", Channel: "C1", Message: "3.4"}},
+		},
+	})
+	if len(telegram.expired) != 1 || telegram.expired[0].MessageID != 3 {
+		t.Errorf("telegram expired %+v, want message 3", telegram.expired)
+	}
+	if len(slack.expired) != 2 || slack.expired[0].Message != "1.2" || slack.expired[1].Message != "3.4" {
+		t.Errorf("slack expired %+v, want messages 1.2 and 3.4", slack.expired)
+	}
+	tests := []struct {
+		id   string
+		want QuestionStatus
+	}{
+		{"pending", QuestionStatus_Expired},
+		{"past deadline", QuestionStatus_TimedOut},
+	}
+	for _, tt := range tests {
+		pq := questions.Get(tt.id)
+		if pq == nil || pq.Status() != tt.want {
+			t.Errorf("question %q = %+v, want status %v", tt.id, pq, tt.want)
+		}
+	}
+}
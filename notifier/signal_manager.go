@@ -0,0 +1,159 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type signalGroupInfo struct {
+	GroupID string `json:"groupId"`
+}
+
+type signalQuote struct {
+	ID int64 `json:"id"`
+}
+
+type signalDataMessage struct {
+	Timestamp int64            `json:"timestamp"`
+	Message   string           `json:"message"`
+	GroupInfo *signalGroupInfo `json:"groupInfo"`
+	Quote     *signalQuote     `json:"quote"`
+}
+
+type signalEnvelope struct {
+	Source       string             `json:"source"`
+	SourceNumber string             `json:"sourceNumber"`
+	SourceUUID   string             `json:"sourceUuid"`
+	SourceName   string             `json:"sourceName"`
+	DataMessage  *signalDataMessage `json:"dataMessage"`
+}
+
+type signalReceivedMessage struct {
+	Envelope signalEnvelope `json:"envelope"`
+}
+
+type signalMessageListener struct {
+	handler func(envelope *signalEnvelope) bool
+	ID      int64
+}
+
+// SignalManager polls the messages received by the accounts of a
+// signal-cli-rest-api instance and dispatches them to the Signal sinks waiting
+// for answers. Receiving a message removes it from signal-cli, so there is a
+// single poller per account, which only runs while there are listeners.
+type SignalManager struct {
+	mutex     sync.Mutex
+	listeners map[string][]*signalMessageListener
+	polling   map[string]bool
+	client    *http.Client
+}
+
+func NewSignalManager() *SignalManager {
+	return &SignalManager{
+		listeners: make(map[string][]*signalMessageListener),
+		polling:   make(map[string]bool),
+		client: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+	}
+}
+
+func signalAccountKey(apiURL string, number string) string {
+	return strings.TrimSuffix(apiURL, "/") + "|" + number
+}
+
+// AddMessageListener registers a handler for the messages received by the
+// account. The listeners added last are called first, and a handler returns
+// true to stop the message from being passed to the older listeners.
+func (m *SignalManager) AddMessageListener(apiURL string, number string, handler func(envelope *signalEnvelope) bool) func() {
+	key := signalAccountKey(apiURL, number)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	added := &signalMessageListener{
+		handler: handler,
+		ID:      rand.Int63(),
+	}
+	m.listeners[key] = append(m.listeners[key], added)
+	if !m.polling[key] {
+		m.polling[key] = true
+		go m.poll(key, apiURL, number)
+	}
+	return func() {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		for i, l := range m.listeners[key] {
+			if l.ID == added.ID {
+				m.listeners[key] = append(m.listeners[key][:i], m.listeners[key][i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (m *SignalManager) poll(key string, apiURL string, number string) {
+	for {
+		m.mutex.Lock()
+		if len(m.listeners[key]) == 0 {
+			m.polling[key] = false
+			m.mutex.Unlock()
+			return
+		}
+		m.mutex.Unlock()
+
+		messages, err := m.receive(apiURL, number)
+		if err != nil {
+			log.Printf("Failed to receive signal messages for %v: %v", number, err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		for i := range messages {
+			if messages[i].Envelope.DataMessage != nil {
+				m.dispatch(key, &messages[i].Envelope)
+			}
+		}
+		if len(messages) == 0 {
+			time.Sleep(time.Second)
+		}
+	}
+}
+
+func (m *SignalManager) receive(apiURL string, number string) ([]signalReceivedMessage, error) {
+	resp, err := m.client.Get(fmt.Sprintf("%v/v1/receive/%v?timeout=10", strings.TrimSuffix(apiURL, "/"), url.PathEscape(number)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("signal-cli-rest-api responded with status %v: %v", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	var messages []signalReceivedMessage
+	if err := json.NewDecoder(resp.Body).Decode(&messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (m *SignalManager) dispatch(key string, envelope *signalEnvelope) {
+	m.mutex.Lock()
+	listeners := append([]*signalMessageListener{}, m.listeners[key]...)
+	m.mutex.Unlock()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic in signal message handler: %v", r)
+		}
+	}()
+	for i := len(listeners) - 1; i >= 0; i-- {
+		if listeners[i].handler(envelope) {
+			return
+		}
+	}
+}
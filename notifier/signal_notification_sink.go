@@ -0,0 +1,239 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignalNotificationSink sends notifications through a signal-cli-rest-api
+// instance to phone numbers or groups ("group.<id>" as listed by the API).
+// Questions are answered by replying with yes/no, the number of an option or
+// the answer text; quoting the question picks it when several are pending.
+type SignalNotificationSink struct {
+	APIURL        string
+	Number        string // the registered number the messages are sent from
+	Recipients    []string
+	AllowedUsers  []string // numbers or UUIDs, anyone in the conversation can answer when empty
+	SignalManager *SignalManager
+	client        *http.Client
+}
+
+type signalSendRequest struct {
+	Message    string   `json:"message"`
+	Number     string   `json:"number"`
+	Recipients []string `json:"recipients"`
+}
+
+type signalSendResponse struct {
+	Timestamp interface{} `json:"timestamp"`
+}
+
+func (sink *SignalNotificationSink) Init() error {
+	if sink.APIURL == "" || sink.Number == "" || len(sink.Recipients) == 0 {
+		return fmt.Errorf("api_url, number and recipients must be set")
+	}
+	sink.APIURL = strings.TrimSuffix(sink.APIURL, "/")
+	sink.client = &http.Client{
+		Timeout: 30 * time.Second,
+	}
+	resp, err := sink.client.Get(sink.APIURL + "/v1/about")
+	if err != nil {
+		return fmt.Errorf("failed to connect to signal-cli-rest-api: %w", err)
+	}
+	resp.Body.Close()
+	log.Printf("Successfully initialized %T", *sink)
+	return nil
+}
+
+func (sink *SignalNotificationSink) DeliverNotification(notification *Notification) error {
+	message := notification.Body
+	if notification.Title != "" {
+		message = notification.Title + "\n\n" + message
+	}
+	_, err := sink.send(message)
+	return err
+}
+
+// send sends the message to all recipients and returns its timestamp, which identifies it in quotes.
+func (sink *SignalNotificationSink) send(message string) (int64, error) {
+	body, err := json.Marshal(&signalSendRequest{
+		Message:    message,
+		Number:     sink.Number,
+		Recipients: sink.Recipients,
+	})
+	if err != nil {
+		return 0, err
+	}
+	resp, err := sink.client.Post(sink.APIURL+"/v2/send", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return 0, fmt.Errorf("signal-cli-rest-api responded with status %v: %v", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	var sent signalSendResponse
+	if err := json.NewDecoder(resp.Body).Decode(&sent); err != nil {
+		return 0, nil
+	}
+	// the timestamp is a string in recent versions of the API and a number in older ones
+	switch timestamp := sent.Timestamp.(type) {
+	case string:
+		t, _ := strconv.ParseInt(timestamp, 10, 64)
+		return t, nil
+	case float64:
+		return int64(timestamp), nil
+	}
+	return 0, nil
+}
+
+func (sink *SignalNotificationSink) AskQuestion(ctx context.Context, question *Question) (*Answer, error) {
+	var hint string
+	switch question.Kind {
+	case QuestionKind_YesNo:
+		hint = "Reply with yes or no."
+	case QuestionKind_Choice:
+		options := []string{}
+		for i, option := range question.Options {
+			options = append(options, fmt.Sprintf("%v. %v", i+1, option.Label))
+		}
+		hint = strings.Join(options, "\n") + "\n\nReply with the number of an option."
+	case QuestionKind_Text:
+		hint = "Reply with your answer."
+	default:
+		return nil, fmt.Errorf("unsupported question kind: %v", question.Kind)
+	}
+
+	questionAskedTime := time.Now()
+	answerChan := make(chan *Answer, 1)
+	var sentTimestamp int64
+	sentChan := make(chan struct{})
+	// the listener is added before sending, so that quick answers are not missed
+	removeListener := sink.SignalManager.AddMessageListener(sink.APIURL, sink.Number, func(envelope *signalEnvelope) bool {
+		<-sentChan
+		msg := envelope.DataMessage
+		if msg.Message == "" || !sink.isFromConversation(envelope) {
+			return false
+		}
+		quoted := msg.Quote != nil && sentTimestamp != 0 && msg.Quote.ID == sentTimestamp
+		if msg.Quote != nil && !quoted {
+			return false
+		}
+		value, label, ok := parseTextAnswer(question, msg.Message)
+		if !ok {
+			if quoted {
+				sink.reply("Unrecognized answer. " + hint)
+				return true
+			}
+			return false
+		}
+		if !sink.isAllowedToAnswer(envelope) {
+			sink.reply("You are not allowed to answer this question")
+			return true
+		}
+		user := envelope.SourceNumber
+		if user == "" {
+			user = envelope.Source
+		}
+		select {
+		case answerChan <- &Answer{
+			Value:          value,
+			AnwserDuration: time.Since(questionAskedTime),
+			AnsweredBy: &Answerer{
+				Sink:        "signal",
+				ID:          envelope.SourceUUID,
+				Username:    user,
+				DisplayName: envelope.SourceName,
+			},
+			AnsweredAt: time.Now(),
+		}:
+			name := envelope.SourceName
+			if name == "" {
+				name = user
+			}
+			sink.reply(fmt.Sprintf("Answered: %v (by %v)", label, name))
+		default:
+		}
+		return true
+	})
+	defer removeListener()
+
+	timestamp, err := sink.send(question.Text + "\n\n" + hint)
+	sentTimestamp = timestamp
+	close(sentChan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send question: %w", err)
+	}
+
+	select {
+	case answer := <-answerChan:
+		return answer, nil
+	case <-ctx.Done():
+		sink.reply(fmt.Sprintf("%v\n%v", truncateText(question.Text, 100), question.TimeoutLabel()))
+		return &Answer{
+			Value:          question.TimeoutValue(),
+			TimedOut:       true,
+			AnwserDuration: time.Since(questionAskedTime),
+		}, nil
+	}
+}
+
+func (sink *SignalNotificationSink) reply(message string) {
+	if _, err := sink.send(message); err != nil {
+		log.Printf("failed to send signal message: %v", err)
+	}
+}
+
+// isFromConversation reports whether the message was sent in a group or a
+// direct conversation the sink sends its messages to.
+func (sink *SignalNotificationSink) isFromConversation(envelope *signalEnvelope) bool {
+	groupInfo := envelope.DataMessage.GroupInfo
+	for _, recipient := range sink.Recipients {
+		if strings.HasPrefix(recipient, "group.") {
+			if groupInfo == nil {
+				continue
+			}
+			groupID, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(recipient, "group."))
+			if err == nil && string(groupID) == groupInfo.GroupID {
+				return true
+			}
+		} else if groupInfo == nil && (recipient == envelope.Source || recipient == envelope.SourceNumber || recipient == envelope.SourceUUID) {
+			return true
+		}
+	}
+	return false
+}
+
+func (sink *SignalNotificationSink) isAllowedToAnswer(envelope *signalEnvelope) bool {
+	if len(sink.AllowedUsers) == 0 {
+		return true
+	}
+	for _, allowed := range sink.AllowedUsers {
+		if allowed == envelope.Source || allowed == envelope.SourceNumber || allowed == envelope.SourceUUID {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	registerSinkType("signal", func(config sinkConfig, managers *sinkManagers) (NotificationSink, error) {
+		return &SignalNotificationSink{
+			APIURL:        config.String("api_url"),
+			Number:        config.String("number"),
+			Recipients:    config.Strings("recipients"),
+			AllowedUsers:  config.Strings("allowed_users"),
+			SignalManager: managers.Signal,
+		}, nil
+	})
+}
@@ -0,0 +1,167 @@
+package notifier
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testSignalAPI implements the parts of signal-cli-rest-api used by the
+// Signal sink. Envelopes queued on the received channel are returned by the
+// next receive request.
+type testSignalAPI struct {
+	*httptest.Server
+	received chan *signalEnvelope
+	sent     chan *signalSendRequest
+}
+
+func newTestSignalAPI(t *testing.T) *testSignalAPI {
+	api := &testSignalAPI{
+		received: make(chan *signalEnvelope, 16),
+		sent:     make(chan *signalSendRequest, 16),
+	}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/about":
+			json.NewEncoder(w).Encode(map[string]interface{}{"versions": []string{"v1", "v2"}})
+		case "/v2/send":
+			var req signalSendRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Error(err)
+			}
+			if req.Number != "+10000000000" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"User is not registered"}`))
+				return
+			}
+			api.sent <- &req
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]string{"timestamp": "1633089600000"})
+		case "/v1/receive/+10000000000":
+			messages := []signalReceivedMessage{}
+			select {
+			case envelope := <-api.received:
+				messages = append(messages, signalReceivedMessage{Envelope: *envelope})
+			case <-time.After(50 * time.Millisecond):
+			}
+			json.NewEncoder(w).Encode(messages)
+		default:
+			t.Errorf("unexpected request %v %v", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(api.Close)
+	return api
+}
+
+func (api *testSignalAPI) nextSent(t *testing.T) *signalSendRequest {
+	t.Helper()
+	select {
+	case req := <-api.sent:
+		return req
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message was sent")
+		return nil
+	}
+}
+
+func TestSignalNotificationSinkDeliverNotification(t *testing.T) {
+	api := newTestSignalAPI(t)
+	sink := &SignalNotificationSink{APIURL: api.URL + "/", Number: "+10000000000", Recipients: []string{"+10000000001"}, SignalManager: NewSignalManager()}
+	if err := sink.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.DeliverNotification(&Notification{Title: "Backup", Body: "finished"}); err != nil {
+		t.Fatal(err)
+	}
+	req := api.nextSent(t)
+	if req.Message != "Backup\n\nfinished" || len(req.Recipients) != 1 || req.Recipients[0] != "+10000000001" {
+		t.Errorf("request = %+v", req)
+	}
+
+	sink.Number = "+19999999999"
+	if err := sink.DeliverNotification(&Notification{Body: "finished"}); err == nil {
+		t.Error("DeliverNotification() succeeded on a 400 response")
+	}
+}
+
+func TestSignalNotificationSinkAskQuestion(t *testing.T) {
+	api := newTestSignalAPI(t)
+	groupID := "group-id"
+	group := &signalGroupInfo{GroupID: groupID}
+	sink := &SignalNotificationSink{
+		APIURL:        api.URL,
+		Number:        "+10000000000",
+		Recipients:    []string{"group." + base64.StdEncoding.EncodeToString([]byte(groupID))},
+		AllowedUsers:  []string{"+10000000001"},
+		SignalManager: NewSignalManager(),
+	}
+	if err := sink.Init(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	answers := make(chan *Answer, 1)
+	go func() {
+		answer, err := sink.AskQuestion(ctx, &Question{
+			Kind:    QuestionKind_Choice,
+			Text:    "Which?",
+			Options: []QuestionOption{{Label: "First", Value: "first"}, {Label: "Second", Value: "second"}},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		answers <- answer
+	}()
+	if req := api.nextSent(t); req.Message != "Which?\n\n1. First\n2. Second\n\nReply with the number of an option." {
+		t.Fatalf("question = %q", req.Message)
+	}
+
+	// replies in other conversations and quotes of other messages are ignored
+	api.received <- &signalEnvelope{SourceNumber: "+10000000001", DataMessage: &signalDataMessage{Message: "1"}}
+	api.received <- &signalEnvelope{SourceNumber: "+10000000001", DataMessage: &signalDataMessage{Message: "1", GroupInfo: group, Quote: &signalQuote{ID: 1}}}
+
+	api.received <- &signalEnvelope{SourceNumber: "+10000000002", DataMessage: &signalDataMessage{Message: "1", GroupInfo: group}}
+	if notice := api.nextSent(t); notice.Message != "You are not allowed to answer this question" {
+		t.Errorf("notice = %q, want a refusal", notice.Message)
+	}
+
+	api.received <- &signalEnvelope{SourceNumber: "+10000000001", DataMessage: &signalDataMessage{Message: "maybe", GroupInfo: group, Quote: &signalQuote{ID: 1633089600000}}}
+	if notice := api.nextSent(t); notice.Message != "Unrecognized answer. 1. First\n2. Second\n\nReply with the number of an option." {
+		t.Errorf("notice = %q, want the hint", notice.Message)
+	}
+
+	api.received <- &signalEnvelope{SourceNumber: "+10000000001", SourceUUID: "uuid", SourceName: "Alice", DataMessage: &signalDataMessage{Message: "2", GroupInfo: group}}
+	answer := <-answers
+	if answer.Value != "second" || answer.AnsweredBy.Username != "+10000000001" || answer.AnsweredBy.ID != "uuid" {
+		t.Errorf("answer = %+v by %+v, want second by +10000000001", answer, answer.AnsweredBy)
+	}
+	if confirmation := api.nextSent(t); confirmation.Message != "Answered: Second (by Alice)" {
+		t.Errorf("confirmation = %q", confirmation.Message)
+	}
+}
+
+func TestSignalNotificationSinkIsFromConversation(t *testing.T) {
+	sink := &SignalNotificationSink{Recipients: []string{"+10000000001", "group." + base64.StdEncoding.EncodeToString([]byte("group-id"))}}
+	tests := []struct {
+		name     string
+		envelope *signalEnvelope
+		want     bool
+	}{
+		{"direct", &signalEnvelope{SourceNumber: "+10000000001", DataMessage: &signalDataMessage{}}, true},
+		{"direct from another number", &signalEnvelope{SourceNumber: "+10000000002", DataMessage: &signalDataMessage{}}, false},
+		{"group", &signalEnvelope{SourceNumber: "+10000000002", DataMessage: &signalDataMessage{GroupInfo: &signalGroupInfo{GroupID: "group-id"}}}, true},
+		{"another group", &signalEnvelope{SourceNumber: "+10000000001", DataMessage: &signalDataMessage{GroupInfo: &signalGroupInfo{GroupID: "other"}}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sink.isFromConversation(tt.envelope); got != tt.want {
+				t.Errorf("isFromConversation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package notifier
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// sinkManagers holds the state shared between the sinks, which is passed to
+// the sink factories.
+type sinkManagers struct {
+	Telegram      *TelegramManager
+	QuestionStore *QuestionStore
+	AnswerLinks   *AnswerLinkManager
+	Slack         *SlackManager
+	Discord       *DiscordManager
+	Matrix        *MatrixManager
+	Signal        *SignalManager
+	IRC           *IRCManager
+	XMPP          *XMPPManager
+}
+
+// configuredSink is a sink created from the config file. ID identifies the
+// sink instance in delivery errors and question votes, so that two sinks of
+// the same type are kept apart.
+type configuredSink struct {
+	ID   string
+	Sink NotificationSink
+}
+
+// sinkConfig is the config file section of a single sink.
+type sinkConfig map[interface{}]interface{}
+
+// sinkFactory creates a sink from its config. The sink is initialized by the caller.
+type sinkFactory func(config sinkConfig, managers *sinkManagers) (NotificationSink, error)
+
+var sinkFactories = map[string]sinkFactory{}
+
+// registerSinkType makes the sink type available in the config file. It is
+// meant to be called from the init function of the file implementing the sink.
+func registerSinkType(name string, factory sinkFactory) {
+	if _, exists := sinkFactories[name]; exists {
+		panic(fmt.Sprintf("sink type %v registered twice", name))
+	}
+	sinkFactories[name] = factory
+}
+
+// sinkTypes returns the names of the registered sink types, sorted.
+func sinkTypes() []string {
+	names := []string{}
+	for name := range sinkFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c sinkConfig) String(key string) string {
+	if c[key] == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", c[key])
+}
+
+// Int returns 0 when the key is not set, and an error when it is not an integer.
+func (c sinkConfig) Int(key string) (int, error) {
+	switch v := c[key].(type) {
+	case nil:
+		return 0, nil
+	case int:
+		return v, nil
+	default:
+		return 0, fmt.Errorf("invalid %v: expected an integer, got %v", key, v)
+	}
+}
+
+func (c sinkConfig) Bool(key string) bool {
+	v, _ := c[key].(bool)
+	return v
+}
+
+// Strings returns a list of strings, a single string is returned as a list with one element.
+func (c sinkConfig) Strings(key string) []string {
+	switch v := c[key].(type) {
+	case []interface{}:
+		values := []string{}
+		for _, item := range v {
+			values = append(values, fmt.Sprintf("%v", item))
+		}
+		return values
+	case nil:
+		return nil
+	default:
+		return []string{fmt.Sprintf("%v", v)}
+	}
+}
+
+func (c sinkConfig) StringMap(key string) map[string]string {
+	values := map[string]string{}
+	if m, ok := c[key].(map[interface{}]interface{}); ok {
+		for name, value := range m {
+			values[fmt.Sprintf("%v", name)] = fmt.Sprintf("%v", value)
+		}
+	}
+	return values
+}
+
+// Duration parses a duration like "10s", it returns 0 when the key is not set.
+func (c sinkConfig) Duration(key string) (time.Duration, error) {
+	if c[key] == nil {
+		return 0, nil
+	}
+	s, ok := c[key].(string)
+	if !ok {
+		return 0, fmt.Errorf("invalid %v: expected a duration like \"10s\", got %v", key, c[key])
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %v: %w", key, err)
+	}
+	return d, nil
+}
@@ -0,0 +1,71 @@
+package notifier
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSinkConfigInt(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    int
+		wantErr bool
+	}{
+		{"not set", nil, 0, false},
+		{"integer", 2, 2, false},
+		{"string", "2", 0, true},
+		{"float", 1.5, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sinkConfig{"qos": tt.value}.Int("qos")
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("Int() = %v, %v, want %v (error: %v)", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSinkConfigDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    time.Duration
+		wantErr bool
+	}{
+		{"not set", nil, 0, false},
+		{"duration", "1m30s", 90 * time.Second, false},
+		{"invalid duration", "soon", 0, true},
+		// a number without a unit is ambiguous
+		{"integer", 10, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sinkConfig{"timeout": tt.value}.Duration("timeout")
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("Duration() = %v, %v, want %v (error: %v)", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSinkFactoriesRejectMistypedValues(t *testing.T) {
+	tests := []struct {
+		sinkType string
+		config   sinkConfig
+	}{
+		{"mqtt", sinkConfig{"broker": "tcp://localhost:1883", "qos": "1"}},
+		{"ntfy", sinkConfig{"topic": "alerts", "priority": "high"}},
+		{"webhook", sinkConfig{"url": "https://example.com", "timeout": 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sinkType, func(t *testing.T) {
+			_, err := sinkFactories[tt.sinkType](tt.config, &sinkManagers{})
+			if err == nil || !strings.HasPrefix(err.Error(), "invalid ") {
+				t.Errorf("factory error = %v, want an invalid value error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package notifier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const slackActionPrefix = "notifier_"
+
+// errSlackSignature is wrapped by the errors of HandleInteraction for requests
+// which were not signed by Slack.
+var errSlackSignature = errors.New("slack request verification failed")
+
+type slackUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+}
+
+type slackAction struct {
+	ActionID string `json:"action_id"`
+	Value    string `json:"value"`
+	Type     string `json:"type"`
+}
+
+// slackInteraction is the subset of the block_actions interaction payload used by the notifier.
+type slackInteraction struct {
+	Type        string        `json:"type"`
+	User        slackUser     `json:"user"`
+	ResponseURL string        `json:"response_url"`
+	Actions     []slackAction `json:"actions"`
+}
+
+// SlackManager receives interaction payloads from Slack on the HTTP server
+// and dispatches them to the Slack sinks waiting for answers.
+type SlackManager struct {
+	mutex          sync.RWMutex
+	signingSecrets []string
+	listeners      map[string]func(interaction *slackInteraction, action *slackAction)
+}
+
+func NewSlackManager() *SlackManager {
+	return &SlackManager{
+		listeners: make(map[string]func(interaction *slackInteraction, action *slackAction)),
+	}
+}
+
+// RegisterSigningSecret allows interactions signed with the secret of a Slack app.
+func (m *SlackManager) RegisterSigningSecret(secret string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, s := range m.signingSecrets {
+		if s == secret {
+			return
+		}
+	}
+	m.signingSecrets = append(m.signingSecrets, secret)
+}
+
+// AddInteractionListener registers a handler for the actions of the
+// question with the given ID. It returns a function removing the listener.
+func (m *SlackManager) AddInteractionListener(questionID string, handler func(interaction *slackInteraction, action *slackAction)) func() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.listeners[questionID] = handler
+	return func() {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		delete(m.listeners, questionID)
+	}
+}
+
+// verifySignature checks the X-Slack-Signature header against all registered signing secrets.
+func (m *SlackManager) verifySignature(timestamp string, signature string, body []byte) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid request timestamp")
+	}
+	if math.Abs(float64(time.Now().Unix()-ts)) > 5*60 {
+		return fmt.Errorf("request timestamp is too old")
+	}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	for _, secret := range m.signingSecrets {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte("v0:" + timestamp + ":"))
+		mac.Write(body)
+		expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+		if hmac.Equal([]byte(expected), []byte(signature)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid request signature")
+}
+
+// HandleInteraction verifies and dispatches an interaction request sent by
+// Slack. The listeners run in the background, since Slack expects the request
+// to be acknowledged within 3 seconds.
+func (m *SlackManager) HandleInteraction(timestamp string, signature string, body []byte) error {
+	if err := m.verifySignature(timestamp, signature, body); err != nil {
+		return fmt.Errorf("%w: %v", errSlackSignature, err)
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return fmt.Errorf("failed to parse interaction: %w", err)
+	}
+	var interaction slackInteraction
+	if err := json.Unmarshal([]byte(form.Get("payload")), &interaction); err != nil {
+		return fmt.Errorf("failed to parse interaction payload: %w", err)
+	}
+	if interaction.Type != "block_actions" {
+		return nil
+	}
+	for i := range interaction.Actions {
+		action := &interaction.Actions[i]
+		if !strings.HasPrefix(action.ActionID, slackActionPrefix) {
+			continue
+		}
+		questionID := strings.SplitN(strings.TrimPrefix(action.ActionID, slackActionPrefix), "_", 2)[0]
+		m.mutex.RLock()
+		handler, ok := m.listeners[questionID]
+		m.mutex.RUnlock()
+		if !ok {
+			log.Printf("received slack action for unknown question %v", questionID)
+			continue
+		}
+		go handler(&interaction, action)
+	}
+	return nil
+}
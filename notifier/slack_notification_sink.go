@@ -0,0 +1,384 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const slackDefaultAPIURL = "https://slack.com/api"
+
+// slackUserIDPattern matches the IDs of users, which start with U or W in Enterprise Grid.
+var slackUserIDPattern = regexp.MustCompile(`^[UW][A-Z0-9]+$`)
+
+// SlackNotificationSink delivers notifications to Slack, either through an
+// incoming webhook or with a bot token and chat.postMessage. Questions are
+// asked with Block Kit buttons, whose interactions Slack sends to the
+// /slack/interactions endpoint of the HTTP server.
+type SlackNotificationSink struct {
+	WebhookURL    string
+	BotToken      string
+	Channel       string
+	SigningSecret string
+	APIURL        string
+	AllowedUsers  []string // user IDs, anyone in the channel can answer when empty
+	SlackManager  *SlackManager
+	QuestionStore *QuestionStore
+	client        *http.Client
+	botUserID     string
+}
+
+type slackMessage struct {
+	Channel         string        `json:"channel,omitempty"`
+	TS              string        `json:"ts,omitempty"`
+	Text            string        `json:"text"`
+	Blocks          []interface{} `json:"blocks"`
+	ReplaceOriginal bool          `json:"replace_original,omitempty"`
+	ResponseType    string        `json:"response_type,omitempty"`
+}
+
+type slackAPIResponse struct {
+	OK      bool   `json:"ok"`
+	Error   string `json:"error"`
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+	UserID  string `json:"user_id"`
+}
+
+func (sink *SlackNotificationSink) Init() error {
+	if sink.WebhookURL == "" && (sink.BotToken == "" || sink.Channel == "") {
+		return fmt.Errorf("either webhook_url or bot_token and channel must be set")
+	}
+	// usernames can be changed and then claimed by someone else, IDs are permanent
+	for _, allowed := range sink.AllowedUsers {
+		if !slackUserIDPattern.MatchString(allowed) {
+			return fmt.Errorf("allowed_users must be user IDs like U0123456789, got %q", allowed)
+		}
+	}
+	if sink.APIURL == "" {
+		sink.APIURL = slackDefaultAPIURL
+	}
+	sink.client = &http.Client{
+		Timeout: 10 * time.Second,
+	}
+	if sink.BotToken != "" {
+		var resp slackAPIResponse
+		if err := sink.callAPI("auth.test", struct{}{}, &resp); err != nil {
+			return fmt.Errorf("failed to authenticate to slack: %w", err)
+		}
+		sink.botUserID = resp.UserID
+	}
+	if sink.SigningSecret != "" {
+		sink.SlackManager.RegisterSigningSecret(sink.SigningSecret)
+	}
+	log.Printf("Successfully initialized %T", *sink)
+	return nil
+}
+
+func (sink *SlackNotificationSink) DeliverNotification(notification *Notification) error {
+	blocks := []interface{}{}
+	if notification.Title != "" {
+		blocks = append(blocks, slackHeaderBlock(notification.Title))
+	}
+	blocks = append(blocks,
+		slackSectionBlock(notification.Body),
+		slackContextBlock(formatDate(notification.Timestamp)),
+	)
+	text := notification.Body
+	if notification.Title != "" {
+		text = notification.Title + "\n" + notification.Body
+	}
+	_, err := sink.postMessage(&slackMessage{Text: text, Blocks: blocks})
+	return err
+}
+
+func (sink *SlackNotificationSink) AskQuestion(ctx context.Context, question *Question) (*Answer, error) {
+	if sink.SigningSecret == "" {
+		return nil, fmt.Errorf("signing_secret must be set to ask questions")
+	}
+	listenerID := fmt.Sprintf("%x", rand.Int63())
+	labels := []string{}
+	values := []interface{}{}
+	switch question.Kind {
+	case QuestionKind_YesNo:
+		labels = append(labels, "Yes", "No")
+		values = append(values, true, false)
+	case QuestionKind_Choice:
+		for _, option := range question.Options {
+			labels = append(labels, option.Label)
+			values = append(values, option.Value)
+		}
+	case QuestionKind_Text:
+	default:
+		return nil, fmt.Errorf("unsupported question kind: %v", question.Kind)
+	}
+
+	questionBlocks := slackQuestionBlocks(question)
+	blocks := append([]interface{}{}, questionBlocks...)
+	if question.Kind == QuestionKind_Text {
+		blocks = append(blocks, map[string]interface{}{
+			"type":            "input",
+			"dispatch_action": true,
+			"label":           slackPlainText("Answer"),
+			"element": map[string]interface{}{
+				"type":      "plain_text_input",
+				"action_id": fmt.Sprintf("%v%v_text", slackActionPrefix, listenerID),
+			},
+		})
+	} else {
+		buttons := []interface{}{}
+		for i, label := range labels {
+			buttons = append(buttons, map[string]interface{}{
+				"type":      "button",
+				"text":      slackPlainText(truncateText(label, 75)),
+				"action_id": fmt.Sprintf("%v%v_%v", slackActionPrefix, listenerID, i),
+				"value":     strconv.Itoa(i),
+			})
+		}
+		blocks = append(blocks, map[string]interface{}{
+			"type":     "actions",
+			"elements": buttons,
+		})
+	}
+
+	questionAskedTime := time.Now()
+	answerChan := make(chan *Answer, 1)
+	removeListener := sink.SlackManager.AddInteractionListener(listenerID, func(interaction *slackInteraction, action *slackAction) {
+		if !sink.isAllowedToAnswer(&interaction.User) {
+			sink.respond(interaction.ResponseURL, &slackMessage{
+				Text:         "You are not allowed to answer this question",
+				ResponseType: "ephemeral",
+			})
+			return
+		}
+		var value interface{}
+		var label string
+		if question.Kind == QuestionKind_Text {
+			value = action.Value
+			label = action.Value
+		} else {
+			i, err := strconv.Atoi(action.Value)
+			if err != nil || i < 0 || i >= len(values) {
+				return
+			}
+			value = values[i]
+			label = labels[i]
+		}
+		sink.respond(interaction.ResponseURL, &slackMessage{
+			Text:            question.Text,
+			Blocks:          append(append([]interface{}{}, questionBlocks...), slackContextBlock(fmt.Sprintf("Answered: %v (by %v)", label, interaction.User.Name))),
+			ReplaceOriginal: true,
+		})
+		select {
+		case answerChan <- &Answer{
+			Value:          value,
+			AnwserDuration: time.Since(questionAskedTime),
+			AnsweredBy: &Answerer{
+				Sink:        "slack",
+				ID:          interaction.User.ID,
+				Username:    interaction.User.Username,
+				DisplayName: interaction.User.Name,
+			},
+			AnsweredAt: time.Now(),
+		}:
+		default:
+		}
+	})
+	defer removeListener()
+
+	sent, err := sink.postMessage(&slackMessage{Text: question.Text, Blocks: blocks})
+	if err != nil {
+		return nil, fmt.Errorf("failed to send question: %w", err)
+	}
+	if sent != nil {
+		sink.QuestionStore.recordMessage(question.ID, &StoredQuestionMessage{
+			SinkID:  sink.SinkID(),
+			Channel: sent.Channel,
+			Message: sent.TS,
+		})
+	}
+
+	select {
+	case answer := <-answerChan:
+		return answer, nil
+	case <-ctx.Done():
+		// messages sent through webhooks can only be updated through the response_url of an interaction
+		if sent != nil {
+			var resp slackAPIResponse
+			err := sink.callAPI("chat.update", &slackMessage{
+				Channel: sent.Channel,
+				TS:      sent.TS,
+				Text:    question.Text,
+				Blocks:  append(append([]interface{}{}, questionBlocks...), slackContextBlock(question.TimeoutLabel())),
+			}, &resp)
+			if err != nil {
+				log.Printf("failed to update slack message after question timeout: %v", err)
+			}
+		}
+		return &Answer{
+			Value:          question.TimeoutValue(),
+			TimedOut:       true,
+			AnwserDuration: time.Since(questionAskedTime),
+		}, nil
+	}
+}
+
+func (sink *SlackNotificationSink) SinkID() string {
+	return fmt.Sprintf("slack:%v:%v", sink.botUserID, sink.Channel)
+}
+
+// ExpireQuestion replaces the buttons of a question message left over from a
+// previous run. Only the messages sent with the bot token are recorded.
+func (sink *SlackNotificationSink) ExpireQuestion(question *Question, message *StoredQuestionMessage) error {
+	var resp slackAPIResponse
+	return sink.callAPI("chat.update", &slackMessage{
+		Channel: message.Channel,
+		TS:      message.Message,
+		Text:    question.Text,
+		Blocks:  append(slackQuestionBlocks(question), slackContextBlock(questionExpiredLabel)),
+	}, &resp)
+}
+
+func (sink *SlackNotificationSink) isAllowedToAnswer(user *slackUser) bool {
+	if len(sink.AllowedUsers) == 0 {
+		return true
+	}
+	for _, allowed := range sink.AllowedUsers {
+		if allowed == user.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// postMessage sends the message with chat.postMessage when a bot token is
+// configured, or to the incoming webhook otherwise. The API response is only
+// returned in the former case.
+func (sink *SlackNotificationSink) postMessage(msg *slackMessage) (*slackAPIResponse, error) {
+	if sink.BotToken != "" {
+		msg.Channel = sink.Channel
+		var resp slackAPIResponse
+		if err := sink.callAPI("chat.postMessage", msg, &resp); err != nil {
+			return nil, err
+		}
+		return &resp, nil
+	}
+	return nil, sink.postJSON(sink.WebhookURL, msg)
+}
+
+func (sink *SlackNotificationSink) respond(responseURL string, msg *slackMessage) {
+	if err := sink.postJSON(responseURL, msg); err != nil {
+		log.Printf("failed to respond to slack interaction: %v", err)
+	}
+}
+
+// callAPI calls a Slack Web API method and fails when it does not respond with ok.
+func (sink *SlackNotificationSink) callAPI(method string, payload interface{}, resp *slackAPIResponse) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, sink.APIURL+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+sink.BotToken)
+	httpResp, err := sink.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	if err := json.NewDecoder(httpResp.Body).Decode(resp); err != nil {
+		return fmt.Errorf("failed to decode %v response (status %v): %w", method, httpResp.StatusCode, err)
+	}
+	if !resp.OK {
+		return fmt.Errorf("%v failed: %v", method, resp.Error)
+	}
+	return nil
+}
+
+func (sink *SlackNotificationSink) postJSON(url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := sink.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("slack responded with status %v: %v", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+func slackPlainText(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "plain_text",
+		"text": text,
+	}
+}
+
+func slackHeaderBlock(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "header",
+		"text": slackPlainText(truncateText(text, 150)),
+	}
+}
+
+func slackSectionBlock(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "section",
+		"text": map[string]interface{}{
+			"type": "mrkdwn",
+			"text": truncateText(text, 3000),
+		},
+	}
+}
+
+// slackQuestionBlocks returns the blocks showing the question, without the blocks used to answer it.
+func slackQuestionBlocks(question *Question) []interface{} {
+	return []interface{}{
+		slackSectionBlock(question.Text),
+		slackContextBlock(formatDate(question.Timestamp)),
+	}
+}
+
+func slackContextBlock(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "context",
+		"elements": []interface{}{
+			map[string]interface{}{
+				"type": "mrkdwn",
+				"text": text,
+			},
+		},
+	}
+}
+
+func init() {
+	registerSinkType("slack", func(config sinkConfig, managers *sinkManagers) (NotificationSink, error) {
+		return &SlackNotificationSink{
+			WebhookURL:    config.String("webhook_url"),
+			BotToken:      config.String("bot_token"),
+			Channel:       config.String("channel"),
+			SigningSecret: config.String("signing_secret"),
+			APIURL:        config.String("api_url"),
+			AllowedUsers:  config.Strings("allowed_users"),
+			SlackManager:  managers.Slack,
+			QuestionStore: managers.QuestionStore,
+		}, nil
+	})
+}
@@ -0,0 +1,323 @@
+package notifier
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func signSlackRequest(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestSlackManagerVerifySignature(t *testing.T) {
+	manager := NewSlackManager()
+	manager.RegisterSigningSecret("first")
+	manager.RegisterSigningSecret("second")
+	body := []byte("payload=%7B%7D")
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+	tests := []struct {
+		name      string
+		timestamp string
+		signature string
+		body      []byte
+		wantErr   bool
+	}{
+		{"first secret", now, signSlackRequest("first", now, body), body, false},
+		{"second secret", now, signSlackRequest("second", now, body), body, false},
+		{"unknown secret", now, signSlackRequest("third", now, body), body, true},
+		{"tampered body", now, signSlackRequest("first", now, body), []byte("payload=%7B%22a%22%7D"), true},
+		{"old timestamp", old, signSlackRequest("first", old, body), body, true},
+		{"invalid timestamp", "yesterday", signSlackRequest("first", "yesterday", body), body, true},
+		{"missing signature", now, "", body, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := manager.verifySignature(tt.timestamp, tt.signature, tt.body)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifySignature() = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// testSlackAPI records the requests sent to the Slack Web API, incoming
+// webhooks and response URLs.
+type testSlackAPI struct {
+	*httptest.Server
+	mutex     sync.Mutex
+	messages  []map[string]interface{}
+	responses chan map[string]interface{}
+	updates   chan map[string]interface{}
+}
+
+func newTestSlackAPI(t *testing.T) *testSlackAPI {
+	api := &testSlackAPI{
+		responses: make(chan map[string]interface{}, 16),
+		updates:   make(chan map[string]interface{}, 16),
+	}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode %v request: %v", r.URL.Path, err)
+		}
+		switch r.URL.Path {
+		case "/api/auth.test":
+		case "/api/chat.postMessage", "/webhook":
+			api.mutex.Lock()
+			api.messages = append(api.messages, body)
+			api.mutex.Unlock()
+		case "/api/chat.update":
+			api.updates <- body
+		case "/response":
+			api.responses <- body
+			return
+		default:
+			t.Errorf("unexpected request to %v", r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(&slackAPIResponse{OK: true, Channel: "C1", TS: "1.2", UserID: "UBOT"})
+	}))
+	t.Cleanup(api.Close)
+	return api
+}
+
+func (api *testSlackAPI) waitForMessage(t *testing.T) map[string]interface{} {
+	t.Helper()
+	for i := 0; i < 500; i++ {
+		api.mutex.Lock()
+		if len(api.messages) > 0 {
+			message := api.messages[0]
+			api.mutex.Unlock()
+			return message
+		}
+		api.mutex.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("no message was posted")
+	return nil
+}
+
+func (api *testSlackAPI) waitForResponse(t *testing.T) map[string]interface{} {
+	t.Helper()
+	select {
+	case response := <-api.responses:
+		return response
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing was posted to the response_url")
+		return nil
+	}
+}
+
+func (api *testSlackAPI) waitForUpdate(t *testing.T) map[string]interface{} {
+	t.Helper()
+	select {
+	case update := <-api.updates:
+		return update
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message was updated")
+		return nil
+	}
+}
+
+// slackButtonActionID returns the action ID of the button with the given value.
+func slackButtonActionID(t *testing.T, message map[string]interface{}, value string) string {
+	t.Helper()
+	blocks, _ := message["blocks"].([]interface{})
+	for _, block := range blocks {
+		elements, _ := block.(map[string]interface{})["elements"].([]interface{})
+		for _, element := range elements {
+			button, _ := element.(map[string]interface{})
+			if button["type"] == "button" && button["value"] == value {
+				return button["action_id"].(string)
+			}
+		}
+	}
+	t.Fatalf("no button with value %v in %v", value, message)
+	return ""
+}
+
+func sendSlackInteraction(t *testing.T, manager *SlackManager, secret string, interaction *slackInteraction) {
+	t.Helper()
+	payload, err := json.Marshal(interaction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte(url.Values{"payload": {string(payload)}}.Encode())
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	if err := manager.HandleInteraction(timestamp, signSlackRequest(secret, timestamp, body), body); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSlackNotificationSinkDeliverNotification(t *testing.T) {
+	api := newTestSlackAPI(t)
+	sink := &SlackNotificationSink{WebhookURL: api.URL + "/webhook", SlackManager: NewSlackManager()}
+	if err := sink.Init(); err != nil {
+		t.Fatal(err)
+	}
+	err := sink.DeliverNotification(&Notification{Title: "Backup", Body: "finished", Timestamp: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := api.waitForMessage(t)
+	if message["text"] != "Backup\nfinished" {
+		t.Errorf("text = %q, want %q", message["text"], "Backup\nfinished")
+	}
+	if blocks, _ := message["blocks"].([]interface{}); len(blocks) != 3 {
+		t.Errorf("got %v blocks, want a header, a section and a context block", len(blocks))
+	}
+}
+
+func TestSlackNotificationSinkAskQuestion(t *testing.T) {
+	api := newTestSlackAPI(t)
+	manager := NewSlackManager()
+	sink := &SlackNotificationSink{
+		BotToken:      "xoxb-test",
+		Channel:       "C1",
+		SigningSecret: "secret",
+		APIURL:        api.URL + "/api",
+		AllowedUsers:  []string{"U1"},
+		SlackManager:  manager,
+	}
+	if err := sink.Init(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	answers := make(chan *Answer, 1)
+	go func() {
+		answer, err := sink.AskQuestion(ctx, &Question{Kind: QuestionKind_YesNo, Text: "Deploy?", Timestamp: time.Now()})
+		if err != nil {
+			t.Error(err)
+		}
+		answers <- answer
+	}()
+	message := api.waitForMessage(t)
+	if message["channel"] != "C1" {
+		t.Errorf("channel = %v, want C1", message["channel"])
+	}
+	actionID := slackButtonActionID(t, message, "0")
+
+	sendSlackInteraction(t, manager, "secret", &slackInteraction{
+		Type:        "block_actions",
+		User:        slackUser{ID: "U2", Username: "mallory"},
+		ResponseURL: api.URL + "/response",
+		Actions:     []slackAction{{ActionID: actionID, Value: "0", Type: "button"}},
+	})
+	if response := api.waitForResponse(t); response["response_type"] != "ephemeral" {
+		t.Errorf("a user missing from allowed_users got %v, want an ephemeral refusal", response)
+	}
+
+	sendSlackInteraction(t, manager, "secret", &slackInteraction{
+		Type:        "block_actions",
+		User:        slackUser{ID: "U1", Username: "alice", Name: "Alice"},
+		ResponseURL: api.URL + "/response",
+		Actions:     []slackAction{{ActionID: actionID, Value: "0", Type: "button"}},
+	})
+	if response := api.waitForResponse(t); response["replace_original"] != true || !strings.Contains(response["text"].(string), "Deploy?") {
+		t.Errorf("response = %v, want the question message replaced", response)
+	}
+	answer := <-answers
+	if answer.Value != true || answer.AnsweredBy == nil || answer.AnsweredBy.ID != "U1" {
+		t.Errorf("answer = %+v, want yes from U1", answer)
+	}
+}
+
+func TestSlackNotificationSinkAllowedUsers(t *testing.T) {
+	tests := []struct {
+		allowed string
+		wantErr bool
+	}{
+		{"U0123456789", false},
+		{"W0123456789", false},
+		{"alice", true},
+		{"@alice", true},
+		{"u0123456789", true},
+	}
+	for _, tt := range tests {
+		sink := &SlackNotificationSink{WebhookURL: "https://hooks.slack.invalid/services/x", AllowedUsers: []string{tt.allowed}, SlackManager: NewSlackManager()}
+		if err := sink.Init(); (err != nil) != tt.wantErr {
+			t.Errorf("Init() with allowed user %q = %v, want error: %v", tt.allowed, err, tt.wantErr)
+		}
+	}
+
+	// a username equal to an allowed ID does not match
+	sink := &SlackNotificationSink{AllowedUsers: []string{"U0123456789"}}
+	if sink.isAllowedToAnswer(&slackUser{ID: "U999", Username: "U0123456789"}) {
+		t.Error("a user was allowed by their username")
+	}
+	if !sink.isAllowedToAnswer(&slackUser{ID: "U0123456789", Username: "alice"}) {
+		t.Error("the allowed user ID was refused")
+	}
+}
+
+func TestSlackNotificationSinkExpireQuestion(t *testing.T) {
+	api := newTestSlackAPI(t)
+	storePath := filepath.Join(t.TempDir(), "questions.json")
+	store := NewQuestionStore(storePath)
+	sink := &SlackNotificationSink{
+		BotToken:      "xoxb-test",
+		Channel:       "C1",
+		SigningSecret: "secret",
+		APIURL:        api.URL + "/api",
+		SlackManager:  NewSlackManager(),
+		QuestionStore: store,
+	}
+	if err := sink.Init(); err != nil {
+		t.Fatal(err)
+	}
+	question := &Question{ID: "q1", Kind: QuestionKind_YesNo, Text: "Deploy?", Timestamp: time.Now()}
+	if err := store.Add(question, "alice", time.Now().Add(time.Hour), ""); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sink.AskQuestion(ctx, question)
+		close(done)
+	}()
+	api.waitForMessage(t)
+	// the notifier stops without updating the message
+	cancel()
+	<-done
+	api.waitForUpdate(t)
+
+	stored, err := NewQuestionStore(storePath).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 1 || len(stored[0].Messages) != 1 {
+		t.Fatalf("stored = %+v, want the question with its message", stored)
+	}
+	message := stored[0].Messages[0]
+	if message.SinkID != "slack:UBOT:C1" || message.Channel != "C1" || message.Message != "1.2" {
+		t.Errorf("stored message = %+v", message)
+	}
+	if err := sink.ExpireQuestion(stored[0].Question, message); err != nil {
+		t.Fatal(err)
+	}
+	update := api.waitForUpdate(t)
+	if update["channel"] != "C1" || update["ts"] != "1.2" {
+		t.Errorf("updated %v %v, want the question message", update["channel"], update["ts"])
+	}
+	blocks, _ := update["blocks"].([]interface{})
+	if len(blocks) == 0 || !strings.Contains(fmt.Sprint(blocks[len(blocks)-1]), questionExpiredLabel) {
+		t.Errorf("blocks = %v, want the buttons replaced with %q", blocks, questionExpiredLabel)
+	}
+}
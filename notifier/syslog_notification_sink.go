@@ -0,0 +1,184 @@
+package notifier
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	syslogDefaultAddress = "/dev/log"
+	// syslogStructuredDataID uses the private enterprise number reserved for documentation (RFC 5612).
+	syslogStructuredDataID = "notifier@32473"
+)
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// SyslogNotificationSink sends notifications as RFC 5424 syslog messages over
+// UDP, TCP (with octet counting framing) or a unix socket. The title and
+// timestamp of the notification are sent as structured data.
+type SyslogNotificationSink struct {
+	Network  string // "udp", "tcp" or "unix"
+	Address  string
+	Facility string
+	AppName  string
+	Severity Severity // used for notifications without a severity
+	hostname string
+	mutex    sync.Mutex
+	conn     net.Conn
+}
+
+func (sink *SyslogNotificationSink) Init() error {
+	if sink.Network == "" {
+		sink.Network = "unix"
+	}
+	switch sink.Network {
+	case "udp", "tcp", "unix":
+	default:
+		return fmt.Errorf("unsupported network: %v", sink.Network)
+	}
+	if sink.Address == "" {
+		if sink.Network != "unix" {
+			return fmt.Errorf("address is not set")
+		}
+		sink.Address = syslogDefaultAddress
+	}
+	if sink.Facility == "" {
+		sink.Facility = "user"
+	}
+	if _, ok := syslogFacilities[sink.Facility]; !ok {
+		return fmt.Errorf("unknown facility: %v", sink.Facility)
+	}
+	if sink.AppName == "" {
+		sink.AppName = "notifier"
+	}
+	if sink.Severity == "" {
+		sink.Severity = Severity_Notice
+	}
+	if _, err := sink.Severity.SyslogLevel(); err != nil {
+		return err
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "-"
+	}
+	sink.hostname = hostname
+	if err := sink.connect(); err != nil {
+		return err
+	}
+	log.Printf("Successfully initialized %T", sink)
+	return nil
+}
+
+func (sink *SyslogNotificationSink) connect() error {
+	if sink.conn != nil {
+		sink.conn.Close()
+		sink.conn = nil
+	}
+	var conn net.Conn
+	var err error
+	if sink.Network == "unix" {
+		// the local syslog socket is usually a datagram socket
+		conn, err = net.Dial("unixgram", sink.Address)
+		if err != nil {
+			conn, err = net.Dial("unix", sink.Address)
+		}
+	} else {
+		conn, err = net.DialTimeout(sink.Network, sink.Address, 10*time.Second)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %v: %w", sink.Address, err)
+	}
+	sink.conn = conn
+	return nil
+}
+
+func (sink *SyslogNotificationSink) DeliverNotification(notification *Notification) error {
+	severity := notification.Severity
+	if severity == "" {
+		severity = sink.Severity
+	}
+	level, err := severity.SyslogLevel()
+	if err != nil {
+		return err
+	}
+	msg := sink.formatMessage(syslogFacilities[sink.Facility]*8+level, notification)
+	if sink.Network == "tcp" {
+		msg = strconv.Itoa(len(msg)) + " " + msg
+	}
+
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if sink.conn == nil {
+		if err := sink.connect(); err != nil {
+			return err
+		}
+	}
+	if _, err := sink.conn.Write([]byte(msg)); err != nil {
+		// the connection may have been closed by the server, retry once with a new one
+		if err := sink.connect(); err != nil {
+			return err
+		}
+		_, err = sink.conn.Write([]byte(msg))
+		return err
+	}
+	return nil
+}
+
+// formatMessage renders the notification as an RFC 5424 message.
+func (sink *SyslogNotificationSink) formatMessage(priority int, notification *Notification) string {
+	structuredData := fmt.Sprintf(`[%v title="%v" timestamp="%v"]`,
+		syslogStructuredDataID,
+		escapeSyslogParam(notification.Title),
+		notification.Timestamp.Format(time.RFC3339Nano),
+	)
+	return fmt.Sprintf("<%v>1 %v %v %v %v - %v %v",
+		priority,
+		time.Now().Format(time.RFC3339Nano),
+		syslogHeaderField(sink.hostname, 255),
+		syslogHeaderField(sink.AppName, 48),
+		os.Getpid(),
+		structuredData,
+		notification.Body,
+	)
+}
+
+// syslogHeaderField replaces the characters not allowed in header fields.
+func syslogHeaderField(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, s)
+	if s == "" {
+		return "-"
+	}
+	return truncateText(s, max)
+}
+
+func escapeSyslogParam(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
+
+func init() {
+	registerSinkType("syslog", func(config sinkConfig, managers *sinkManagers) (NotificationSink, error) {
+		return &SyslogNotificationSink{
+			Network:  config.String("network"),
+			Address:  config.String("address"),
+			Facility: config.String("facility"),
+			AppName:  config.String("app_name"),
+			Severity: Severity(strings.ToLower(config.String("severity"))),
+		}, nil
+	})
+}
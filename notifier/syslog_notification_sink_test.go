@@ -0,0 +1,53 @@
+package notifier
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestSyslogNotificationSinkDeliverNotification(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.sock")
+	syslog := listenTestUnixgram(t, path)
+	sink := &SyslogNotificationSink{Address: path, Facility: "local0", AppName: "backup"}
+	if err := sink.Init(); err != nil {
+		t.Fatal(err)
+	}
+	err := sink.DeliverNotification(&Notification{
+		Title:     `Backup "nightly" [db]`,
+		Body:      "disk full",
+		Severity:  Severity_Error,
+		Timestamp: time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// local0 (16) * 8 + error (3)
+	want := regexp.MustCompile(`^<131>1 \S+ \S+ backup \d+ - \[notifier@32473 title="Backup \\"nightly\\" \[db\\]" timestamp="2021-05-01T12:00:00Z"\] disk full$`)
+	if msg := readTestDatagram(t, syslog); !want.Match(msg) {
+		t.Errorf("message = %q, want it to match %v", msg, want)
+	}
+}
+
+func TestSyslogNotificationSinkReconnect(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.sock")
+	syslog := listenTestUnixgram(t, path)
+	sink := &SyslogNotificationSink{Address: path}
+	if err := sink.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the syslog daemon restarts and creates a new socket
+	syslog.Close()
+	os.Remove(path)
+	syslog = listenTestUnixgram(t, path)
+
+	if err := sink.DeliverNotification(&Notification{Title: "Backup", Body: "finished"}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := readTestDatagram(t, syslog); !regexp.MustCompile(` finished$`).Match(msg) {
+		t.Errorf("message = %q, want the notification", msg)
+	}
+}
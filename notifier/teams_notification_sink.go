@@ -0,0 +1,80 @@
+package notifier
+
+import (
+	"log"
+)
+
+// TeamsNotificationSink posts notifications as Adaptive Cards to a Microsoft
+// Teams incoming webhook or workflow.
+type TeamsNotificationSink struct {
+	incomingWebhook
+}
+
+func (sink *TeamsNotificationSink) Init() error {
+	if err := sink.incomingWebhook.init(); err != nil {
+		return err
+	}
+	log.Printf("Successfully initialized %T", *sink)
+	return nil
+}
+
+func (sink *TeamsNotificationSink) DeliverNotification(notification *Notification) error {
+	body := []interface{}{}
+	if notification.Title != "" {
+		body = append(body, map[string]interface{}{
+			"type":   "TextBlock",
+			"text":   notification.Title,
+			"size":   "Medium",
+			"weight": "Bolder",
+			"color":  teamsTextColor(notification.Severity),
+			"wrap":   true,
+		})
+	}
+	body = append(body,
+		map[string]interface{}{
+			"type": "TextBlock",
+			"text": notification.Body,
+			"wrap": true,
+		},
+		map[string]interface{}{
+			"type":     "TextBlock",
+			"text":     formatDate(notification.Timestamp),
+			"size":     "Small",
+			"isSubtle": true,
+			"spacing":  "Small",
+		},
+	)
+	return sink.post(map[string]interface{}{
+		"type": "message",
+		"attachments": []interface{}{
+			map[string]interface{}{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content": map[string]interface{}{
+					"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+					"type":    "AdaptiveCard",
+					"version": "1.4",
+					"body":    body,
+					"msteams": map[string]interface{}{"width": "Full"},
+				},
+			},
+		},
+	})
+}
+
+func teamsTextColor(severity Severity) string {
+	switch severity {
+	case Severity_Emergency, Severity_Alert, Severity_Critical, Severity_Error:
+		return "Attention"
+	case Severity_Warning:
+		return "Warning"
+	}
+	return "Default"
+}
+
+func init() {
+	registerSinkType("teams", func(config sinkConfig, managers *sinkManagers) (NotificationSink, error) {
+		return &TeamsNotificationSink{
+			incomingWebhook: incomingWebhook{WebhookURL: config.String("webhook_url")},
+		}, nil
+	})
+}
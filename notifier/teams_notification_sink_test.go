@@ -0,0 +1,68 @@
+package notifier
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTeamsNotificationSink(t *testing.T) {
+	server, payloads := newTestIncomingWebhook(t, http.StatusAccepted)
+	sink := &TeamsNotificationSink{incomingWebhook: incomingWebhook{WebhookURL: server.URL}}
+	if err := sink.Init(); err != nil {
+		t.Fatal(err)
+	}
+	timestamp := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	err := sink.DeliverNotification(&Notification{Title: "Disk", Body: "full", Severity: Severity_Warning, Timestamp: timestamp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := <-payloads
+	attachment := firstAttachment(t, payload)
+	if payload["type"] != "message" || attachment["contentType"] != "application/vnd.microsoft.card.adaptive" {
+		t.Fatalf("payload = %v", payload)
+	}
+	card, _ := attachment["content"].(map[string]interface{})
+	body, _ := card["body"].([]interface{})
+	if card["type"] != "AdaptiveCard" || len(body) != 3 {
+		t.Fatalf("card = %v, want a title, body and date block", card)
+	}
+	title, _ := body[0].(map[string]interface{})
+	text, _ := body[1].(map[string]interface{})
+	date, _ := body[2].(map[string]interface{})
+	if title["text"] != "Disk" || title["color"] != "Warning" {
+		t.Errorf("title block = %v", title)
+	}
+	if text["text"] != "full" {
+		t.Errorf("text block = %v", text)
+	}
+	if date["text"] != formatDate(timestamp) {
+		t.Errorf("date block = %v, want %v", date, formatDate(timestamp))
+	}
+
+	if err := sink.DeliverNotification(&Notification{Body: "no title"}); err != nil {
+		t.Fatal(err)
+	}
+	card, _ = firstAttachment(t, <-payloads)["content"].(map[string]interface{})
+	if body, _ := card["body"].([]interface{}); len(body) != 2 {
+		t.Errorf("got %v blocks for a notification without a title, want 2", len(body))
+	}
+}
+
+func TestTeamsTextColor(t *testing.T) {
+	tests := []struct {
+		severity Severity
+		want     string
+	}{
+		{Severity_Emergency, "Attention"},
+		{Severity_Error, "Attention"},
+		{Severity_Warning, "Warning"},
+		{Severity_Notice, "Default"},
+		{"", "Default"},
+	}
+	for _, tt := range tests {
+		if got := teamsTextColor(tt.severity); got != tt.want {
+			t.Errorf("teamsTextColor(%q) = %v, want %v", tt.severity, got, tt.want)
+		}
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log"
 	"math/rand"
+	"strconv"
+	"strings"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
@@ -14,11 +16,19 @@ type TelegramNotificationSink struct {
 	Name            string
 	BotToken        string
 	ChatID          int64
+	AllowedUsers    []string // numeric user IDs, anyone in the chat can answer when empty
 	TelegramManager *TelegramManager
+	QuestionStore   *QuestionStore
 	bot             *tgbotapi.BotAPI
 }
 
 func (sink *TelegramNotificationSink) Init() error {
+	// usernames can be changed and then claimed by someone else, IDs are permanent
+	for _, allowed := range sink.AllowedUsers {
+		if _, err := strconv.ParseInt(allowed, 10, 64); err != nil {
+			return fmt.Errorf("allowed_users must be numeric user IDs, got %q", allowed)
+		}
+	}
 	bot, err := sink.TelegramManager.RegisterBot(sink.BotToken)
 	if err != nil {
 		return err
@@ -50,12 +60,42 @@ func (sink *TelegramNotificationSink) AskQuestion(ctx context.Context, question
 	switch question.Kind {
 	case QuestionKind_YesNo:
 		return sink.askYesNoQuestion(ctx, question)
+	case QuestionKind_Text:
+		return sink.askTextQuestion(ctx, question)
+	case QuestionKind_Choice:
+		return sink.askChoiceQuestion(ctx, question)
 	default:
 		return nil, fmt.Errorf("unsupported question kind: %v", question.Kind)
 	}
 
 }
 
+func (sink *TelegramNotificationSink) SinkID() string {
+	return fmt.Sprintf("telegram:%v:%v", sink.bot.Self.ID, sink.ChatID)
+}
+
+func (sink *TelegramNotificationSink) recordQuestionMessage(question *Question, msgSent *tgbotapi.Message) {
+	sink.QuestionStore.recordMessage(question.ID, &StoredQuestionMessage{
+		SinkID:    sink.SinkID(),
+		ChatID:    msgSent.Chat.ID,
+		MessageID: msgSent.MessageID,
+	})
+}
+
+// ExpireQuestion replaces the text of a question message left over from a
+// previous run, removing its buttons.
+func (sink *TelegramNotificationSink) ExpireQuestion(question *Question, message *StoredQuestionMessage) error {
+	edit := tgbotapi.NewEditMessageText(message.ChatID, message.MessageID, fmt.Sprintf(
+		"%v\n<code>%v</code>\n<i>%v</i>",
+		question.Text,
+		formatDate(question.Timestamp),
+		questionExpiredLabel,
+	))
+	edit.ParseMode = "HTML"
+	_, err := sink.bot.Send(edit)
+	return err
+}
+
 func (sink *TelegramNotificationSink) askYesNoQuestion(ctx context.Context, question *Question) (*Answer, error) {
 	questionID := fmt.Sprintf("%x", rand.Int63())
 	msg := tgbotapi.NewMessage(sink.ChatID, fmt.Sprintf("%v\n<code>%v</code>", question.Text, formatDate(question.Timestamp)))
@@ -71,8 +111,9 @@ func (sink *TelegramNotificationSink) askYesNoQuestion(ctx context.Context, ques
 	if err != nil {
 		return nil, fmt.Errorf("failed to send question: %v", err)
 	}
+	sink.recordQuestionMessage(question, &msgSent)
 	questionAskedTime := time.Now()
-	answerChan := make(chan *Answer)
+	answerChan := make(chan *Answer, 1)
 	removeListener := sink.TelegramManager.AddUpdateListener(sink.BotToken, func(update *tgbotapi.Update) {
 		if update.CallbackQuery == nil {
 			return
@@ -80,6 +121,13 @@ func (sink *TelegramNotificationSink) askYesNoQuestion(ctx context.Context, ques
 		if update.CallbackQuery.Message.Chat.ID != sink.ChatID {
 			return
 		}
+		if !strings.HasSuffix(update.CallbackQuery.Data, "_"+questionID) {
+			return
+		}
+		if !sink.isAllowedToAnswer(update.CallbackQuery.From) {
+			sink.bot.AnswerCallbackQuery(tgbotapi.NewCallbackWithAlert(update.CallbackQuery.ID, "You are not allowed to answer this question"))
+			return
+		}
 		switch update.CallbackQuery.Data {
 		case "yes_" + questionID:
 			_, err := sink.bot.Send(tgbotapi.NewEditMessageReplyMarkup(
@@ -95,10 +143,15 @@ func (sink *TelegramNotificationSink) askYesNoQuestion(ctx context.Context, ques
 				log.Printf("failed to edit message: %v", err)
 			}
 			sink.bot.AnswerCallbackQuery(tgbotapi.NewCallback(update.CallbackQuery.ID, "Yes"))
-			answerChan <- &Answer{
+			select {
+			case answerChan <- &Answer{
 				TimedOut:       false,
 				Value:          true,
 				AnwserDuration: time.Since(questionAskedTime),
+				AnsweredBy:     telegramAnswerer(update.CallbackQuery.From),
+				AnsweredAt:     time.Now(),
+			}:
+			default:
 			}
 		case "no_" + questionID:
 			sink.bot.Send(tgbotapi.NewEditMessageReplyMarkup(
@@ -111,10 +164,15 @@ func (sink *TelegramNotificationSink) askYesNoQuestion(ctx context.Context, ques
 				),
 			))
 			sink.bot.AnswerCallbackQuery(tgbotapi.NewCallback(update.CallbackQuery.ID, "No"))
-			answerChan <- &Answer{
+			select {
+			case answerChan <- &Answer{
 				TimedOut:       false,
 				Value:          false,
 				AnwserDuration: time.Since(questionAskedTime),
+				AnsweredBy:     telegramAnswerer(update.CallbackQuery.From),
+				AnsweredAt:     time.Now(),
+			}:
+			default:
 			}
 		}
 	})
@@ -129,7 +187,7 @@ func (sink *TelegramNotificationSink) askYesNoQuestion(ctx context.Context, ques
 			msgSent.MessageID,
 			tgbotapi.NewInlineKeyboardMarkup(
 				tgbotapi.NewInlineKeyboardRow(
-					tgbotapi.NewInlineKeyboardButtonData("Timed out (No)", "i"),
+					tgbotapi.NewInlineKeyboardButtonData(question.TimeoutLabel(), "i"),
 				),
 			),
 		))
@@ -137,11 +195,277 @@ func (sink *TelegramNotificationSink) askYesNoQuestion(ctx context.Context, ques
 			log.Printf("failed to edit message after question timeout: %v", err)
 		}
 		return &Answer{
-			Value:          false,
+			Value:          question.TimeoutValue(),
+			TimedOut:       true,
+			AnwserDuration: time.Since(questionAskedTime),
+		}, nil
+
+	}
+
+}
+
+func (sink *TelegramNotificationSink) askTextQuestion(ctx context.Context, question *Question) (*Answer, error) {
+	questionText := fmt.Sprintf("%v\n<code>%v</code>", question.Text, formatDate(question.Timestamp))
+	msg := tgbotapi.NewMessage(sink.ChatID, questionText)
+	msg.ParseMode = "HTML"
+	msg.ReplyMarkup = tgbotapi.ForceReply{
+		ForceReply: true,
+		Selective:  false,
+	}
+
+	msgSent, err := sink.bot.Send(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send question: %v", err)
+	}
+	sink.recordQuestionMessage(question, &msgSent)
+	questionAskedTime := time.Now()
+	answerChan := make(chan *Answer, 1)
+	removeListener := sink.TelegramManager.AddUpdateListener(sink.BotToken, func(update *tgbotapi.Update) {
+		if update.Message == nil || update.Message.ReplyToMessage == nil {
+			return
+		}
+		if update.Message.Chat.ID != sink.ChatID || update.Message.ReplyToMessage.MessageID != msgSent.MessageID {
+			return
+		}
+		if !sink.isAllowedToAnswer(update.Message.From) {
+			return
+		}
+		select {
+		case answerChan <- &Answer{
+			TimedOut:       false,
+			Value:          update.Message.Text,
+			AnwserDuration: time.Since(questionAskedTime),
+			AnsweredBy:     telegramAnswerer(update.Message.From),
+			AnsweredAt:     time.Now(),
+		}:
+		default:
+		}
+	})
+	select {
+	case answer := <-answerChan:
+		removeListener()
+		return answer, nil
+	case <-ctx.Done():
+		removeListener()
+		edit := tgbotapi.NewEditMessageText(msgSent.Chat.ID, msgSent.MessageID, fmt.Sprintf("%v\n<i>%v</i>", questionText, question.TimeoutLabel()))
+		edit.ParseMode = "HTML"
+		if _, err := sink.bot.Send(edit); err != nil {
+			log.Printf("failed to edit message after question timeout: %v", err)
+		}
+		return &Answer{
+			Value:          question.TimeoutValue(),
 			TimedOut:       true,
 			AnwserDuration: time.Since(questionAskedTime),
 		}, nil
+	}
+}
 
+func (sink *TelegramNotificationSink) askChoiceQuestion(ctx context.Context, question *Question) (*Answer, error) {
+	questionID := fmt.Sprintf("%x", rand.Int63())
+	msg := tgbotapi.NewMessage(sink.ChatID, fmt.Sprintf("%v\n<code>%v</code>", question.Text, formatDate(question.Timestamp)))
+	msg.ParseMode = "HTML"
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	for i, option := range question.Options {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(option.Label, fmt.Sprintf("opt%v_%v", i, questionID)),
+		))
 	}
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
 
+	msgSent, err := sink.bot.Send(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send question: %v", err)
+	}
+	sink.recordQuestionMessage(question, &msgSent)
+	questionAskedTime := time.Now()
+	answerChan := make(chan *Answer, 1)
+	removeListener := sink.TelegramManager.AddUpdateListener(sink.BotToken, func(update *tgbotapi.Update) {
+		if update.CallbackQuery == nil {
+			return
+		}
+		if update.CallbackQuery.Message.Chat.ID != sink.ChatID {
+			return
+		}
+		if !strings.HasSuffix(update.CallbackQuery.Data, "_"+questionID) {
+			return
+		}
+		if !sink.isAllowedToAnswer(update.CallbackQuery.From) {
+			sink.bot.AnswerCallbackQuery(tgbotapi.NewCallbackWithAlert(update.CallbackQuery.ID, "You are not allowed to answer this question"))
+			return
+		}
+		for i, option := range question.Options {
+			if update.CallbackQuery.Data != fmt.Sprintf("opt%v_%v", i, questionID) {
+				continue
+			}
+			_, err := sink.bot.Send(tgbotapi.NewEditMessageReplyMarkup(
+				msgSent.Chat.ID,
+				msgSent.MessageID,
+				tgbotapi.NewInlineKeyboardMarkup(
+					tgbotapi.NewInlineKeyboardRow(
+						tgbotapi.NewInlineKeyboardButtonData("Answered: "+option.Label, "i"),
+					),
+				),
+			))
+			if err != nil {
+				log.Printf("failed to edit message: %v", err)
+			}
+			sink.bot.AnswerCallbackQuery(tgbotapi.NewCallback(update.CallbackQuery.ID, option.Label))
+			select {
+			case answerChan <- &Answer{
+				TimedOut:       false,
+				Value:          option.Value,
+				AnwserDuration: time.Since(questionAskedTime),
+				AnsweredBy:     telegramAnswerer(update.CallbackQuery.From),
+				AnsweredAt:     time.Now(),
+			}:
+			default:
+			}
+			return
+		}
+	})
+	select {
+	case answer := <-answerChan:
+		removeListener()
+		return answer, nil
+	case <-ctx.Done():
+		removeListener()
+		_, err := sink.bot.Send(tgbotapi.NewEditMessageReplyMarkup(
+			msgSent.Chat.ID,
+			msgSent.MessageID,
+			tgbotapi.NewInlineKeyboardMarkup(
+				tgbotapi.NewInlineKeyboardRow(
+					tgbotapi.NewInlineKeyboardButtonData(question.TimeoutLabel(), "i"),
+				),
+			),
+		))
+		if err != nil {
+			log.Printf("failed to edit message after question timeout: %v", err)
+		}
+		return &Answer{
+			Value:          question.TimeoutValue(),
+			TimedOut:       true,
+			AnwserDuration: time.Since(questionAskedTime),
+		}, nil
+	}
+}
+
+// CollectVotes asks a yes/no question which can be answered by every member of the chat.
+func (sink *TelegramNotificationSink) CollectVotes(ctx context.Context, question *Question, votes chan<- *Answer) error {
+	if question.Kind != QuestionKind_YesNo {
+		return fmt.Errorf("unsupported question kind for votes: %v", question.Kind)
+	}
+	questionID := fmt.Sprintf("%x", rand.Int63())
+	msg := tgbotapi.NewMessage(sink.ChatID, fmt.Sprintf("%v\n<code>%v</code>", question.Text, formatDate(question.Timestamp)))
+	msg.ParseMode = "HTML"
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Yes", "yes_"+questionID),
+			tgbotapi.NewInlineKeyboardButtonData("No", "no_"+questionID),
+		),
+	)
+
+	msgSent, err := sink.bot.Send(msg)
+	if err != nil {
+		return fmt.Errorf("failed to send question: %v", err)
+	}
+	sink.recordQuestionMessage(question, &msgSent)
+	questionAskedTime := time.Now()
+	voted := make(map[int]bool)
+	removeListener := sink.TelegramManager.AddUpdateListener(sink.BotToken, func(update *tgbotapi.Update) {
+		if update.CallbackQuery == nil {
+			return
+		}
+		if update.CallbackQuery.Message.Chat.ID != sink.ChatID {
+			return
+		}
+		if !strings.HasSuffix(update.CallbackQuery.Data, "_"+questionID) {
+			return
+		}
+		if !sink.isAllowedToAnswer(update.CallbackQuery.From) {
+			sink.bot.AnswerCallbackQuery(tgbotapi.NewCallbackWithAlert(update.CallbackQuery.ID, "You are not allowed to answer this question"))
+			return
+		}
+		var value bool
+		switch update.CallbackQuery.Data {
+		case "yes_" + questionID:
+			value = true
+		case "no_" + questionID:
+			value = false
+		default:
+			return
+		}
+		if voted[update.CallbackQuery.From.ID] {
+			sink.bot.AnswerCallbackQuery(tgbotapi.NewCallback(update.CallbackQuery.ID, "You have already voted"))
+			return
+		}
+		voted[update.CallbackQuery.From.ID] = true
+		vote := &Answer{
+			Value:          value,
+			AnwserDuration: time.Since(questionAskedTime),
+			AnsweredBy:     telegramAnswerer(update.CallbackQuery.From),
+			AnsweredAt:     time.Now(),
+		}
+		label, _ := question.labelForValue(value)
+		sink.bot.AnswerCallbackQuery(tgbotapi.NewCallback(update.CallbackQuery.ID, "Voted: "+label))
+		select {
+		case votes <- vote:
+		case <-ctx.Done():
+		}
+	})
+	<-ctx.Done()
+	removeListener()
+	_, err = sink.bot.Send(tgbotapi.NewEditMessageReplyMarkup(
+		msgSent.Chat.ID,
+		msgSent.MessageID,
+		tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("Voting closed", "i"),
+			),
+		),
+	))
+	if err != nil {
+		log.Printf("failed to edit message after voting closed: %v", err)
+	}
+	return nil
+}
+
+func (sink *TelegramNotificationSink) isAllowedToAnswer(user *tgbotapi.User) bool {
+	if user == nil {
+		return false
+	}
+	if len(sink.AllowedUsers) == 0 {
+		return true
+	}
+	for _, allowed := range sink.AllowedUsers {
+		if allowed == fmt.Sprintf("%v", user.ID) {
+			return true
+		}
+	}
+	return false
+}
+
+func telegramAnswerer(user *tgbotapi.User) *Answerer {
+	displayName := user.FirstName
+	if user.LastName != "" {
+		displayName += " " + user.LastName
+	}
+	return &Answerer{
+		Sink:        "telegram",
+		ID:          fmt.Sprintf("%v", user.ID),
+		Username:    user.UserName,
+		DisplayName: displayName,
+	}
+}
+
+func init() {
+	registerSinkType("telegram", func(config sinkConfig, managers *sinkManagers) (NotificationSink, error) {
+		s := &TelegramNotificationSink{
+			TelegramManager: managers.Telegram,
+			QuestionStore:   managers.QuestionStore,
+			BotToken:        config.String("bot_token"),
+			AllowedUsers:    config.Strings("allowed_users"),
+		}
+		fmt.Sscanf(config.String("chat_id"), "%v", &s.ChatID)
+		return s, nil
+	})
 }
@@ -1,6 +1,8 @@
 package notifier
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"time"
 
 	"github.com/spf13/viper"
@@ -22,3 +24,28 @@ func truncateText(s string, max int) string {
 func formatDate(d time.Time) string {
 	return d.Format(viper.GetString("general.date_format"))
 }
+
+// generateID returns a random hex string suitable for identifying questions.
+func generateID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// shortCodeAlphabet leaves out the characters which are easy to confuse.
+const shortCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// generateShortCode returns a code identifying a question in chats where the
+// answers can't reply to a specific message.
+func generateShortCode() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	for i := range b {
+		b[i] = shortCodeAlphabet[int(b[i])%len(shortCodeAlphabet)]
+	}
+	return string(b)
+}
@@ -0,0 +1,192 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+const webSinkNotificationsKept = 50
+
+// errWebQuestionAnswered is returned by WebNotificationSink.Answer when the
+// question already got its answer, or the vote of the user, or timed out.
+var errWebQuestionAnswered = errors.New("the question has already been answered or is no longer waiting for an answer")
+
+type webQuestion struct {
+	question  *Question
+	askedTime time.Time
+	handler   func(answer *Answer) bool // reports whether the answer was accepted
+}
+
+// WebNotificationSink shows notifications and questions in the web UI served
+// by HttpServer, where logged in users can answer them.
+type WebNotificationSink struct {
+	AllowedUsers []string // usernames, every user can answer when empty
+
+	mutex         sync.RWMutex
+	questions     map[string]*webQuestion
+	notifications []*Notification
+}
+
+func NewWebNotificationSink() *WebNotificationSink {
+	return &WebNotificationSink{
+		questions: make(map[string]*webQuestion),
+	}
+}
+
+func (sink *WebNotificationSink) Init() error {
+	log.Printf("Successfully initialized %T", sink)
+	return nil
+}
+
+// DeliverNotification keeps the notification to be shown in the web UI.
+func (sink *WebNotificationSink) DeliverNotification(notification *Notification) error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	sink.notifications = append([]*Notification{notification}, sink.notifications...)
+	if len(sink.notifications) > webSinkNotificationsKept {
+		sink.notifications = sink.notifications[:webSinkNotificationsKept]
+	}
+	return nil
+}
+
+// Notifications returns the most recent notifications, newest first.
+func (sink *WebNotificationSink) Notifications() []*Notification {
+	sink.mutex.RLock()
+	defer sink.mutex.RUnlock()
+	return append([]*Notification{}, sink.notifications...)
+}
+
+func (sink *WebNotificationSink) AskQuestion(ctx context.Context, question *Question) (*Answer, error) {
+	questionAskedTime := time.Now()
+	answerChan := make(chan *Answer, 1)
+	removeQuestion := sink.addQuestion(question, func(answer *Answer) bool {
+		select {
+		case answerChan <- answer:
+			return true
+		default:
+			return false
+		}
+	})
+	defer removeQuestion()
+
+	select {
+	case answer := <-answerChan:
+		return answer, nil
+	case <-ctx.Done():
+		return &Answer{
+			Value:          question.TimeoutValue(),
+			TimedOut:       true,
+			AnwserDuration: time.Since(questionAskedTime),
+		}, nil
+	}
+}
+
+func (sink *WebNotificationSink) CollectVotes(ctx context.Context, question *Question, votes chan<- *Answer) error {
+	voted := make(map[string]bool)
+	var votedMutex sync.Mutex
+	removeQuestion := sink.addQuestion(question, func(answer *Answer) bool {
+		votedMutex.Lock()
+		if voted[answer.AnsweredBy.ID] {
+			votedMutex.Unlock()
+			return false
+		}
+		voted[answer.AnsweredBy.ID] = true
+		votedMutex.Unlock()
+		select {
+		case votes <- answer:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	})
+	defer removeQuestion()
+	<-ctx.Done()
+	return nil
+}
+
+func (sink *WebNotificationSink) addQuestion(question *Question, handler func(answer *Answer) bool) func() {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	sink.questions[question.ID] = &webQuestion{
+		question:  question,
+		askedTime: time.Now(),
+		handler:   handler,
+	}
+	return func() {
+		sink.mutex.Lock()
+		defer sink.mutex.Unlock()
+		delete(sink.questions, question.ID)
+	}
+}
+
+// PendingQuestions returns the questions waiting for an answer, oldest first.
+func (sink *WebNotificationSink) PendingQuestions() []*Question {
+	sink.mutex.RLock()
+	defer sink.mutex.RUnlock()
+	questions := make([]*Question, 0, len(sink.questions))
+	for _, q := range sink.questions {
+		questions = append(questions, q.question)
+	}
+	sort.Slice(questions, func(i, j int) bool {
+		return questions[i].Timestamp.Before(questions[j].Timestamp)
+	})
+	return questions
+}
+
+// Answer answers the question with the given ID on behalf of the user.
+func (sink *WebNotificationSink) Answer(questionID string, user *User, value interface{}) error {
+	if !sink.isAllowedToAnswer(user) {
+		return fmt.Errorf("you are not allowed to answer questions")
+	}
+	sink.mutex.RLock()
+	q, ok := sink.questions[questionID]
+	sink.mutex.RUnlock()
+	if !ok {
+		return errWebQuestionAnswered
+	}
+	if _, ok := q.question.labelForValue(value); !ok || value == "" {
+		return fmt.Errorf("%v is not a valid answer", value)
+	}
+	accepted := q.handler(&Answer{
+		Value:          value,
+		AnwserDuration: time.Since(q.askedTime),
+		AnsweredBy: &Answerer{
+			Sink:     "web",
+			ID:       user.Username,
+			Username: user.Username,
+		},
+		AnsweredAt: time.Now(),
+	})
+	if !accepted {
+		return errWebQuestionAnswered
+	}
+	return nil
+}
+
+func (sink *WebNotificationSink) isAllowedToAnswer(user *User) bool {
+	if user == nil || user.Username == "" {
+		return false
+	}
+	if len(sink.AllowedUsers) == 0 {
+		return true
+	}
+	for _, allowed := range sink.AllowedUsers {
+		if allowed == user.Username {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	registerSinkType("web", func(config sinkConfig, managers *sinkManagers) (NotificationSink, error) {
+		s := NewWebNotificationSink()
+		s.AllowedUsers = config.Strings("allowed_users")
+		return s, nil
+	})
+}
@@ -0,0 +1,105 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// defaultWebhookBodyTemplate sends the notification as JSON.
+const defaultWebhookBodyTemplate = `{{ json . }}`
+
+var webhookTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"date": formatDate,
+}
+
+// WebhookNotificationSink sends notifications as HTTP requests to an arbitrary URL.
+// The body is rendered with a text/template which gets the Notification as its data.
+type WebhookNotificationSink struct {
+	URL          string
+	Method       string
+	Headers      map[string]string
+	BodyTemplate string
+	Timeout      time.Duration
+	client       *http.Client
+	template     *template.Template
+}
+
+func (sink *WebhookNotificationSink) Init() error {
+	if sink.URL == "" {
+		return fmt.Errorf("url is not set")
+	}
+	if sink.Method == "" {
+		sink.Method = http.MethodPost
+	}
+	if sink.BodyTemplate == "" {
+		sink.BodyTemplate = defaultWebhookBodyTemplate
+	}
+	if sink.Timeout <= 0 {
+		sink.Timeout = 10 * time.Second
+	}
+	tmpl, err := template.New("body").Funcs(webhookTemplateFuncs).Parse(sink.BodyTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse body template: %w", err)
+	}
+	sink.template = tmpl
+	sink.client = &http.Client{
+		Timeout: sink.Timeout,
+	}
+	log.Printf("Successfully initialized %T", *sink)
+	return nil
+}
+
+func (sink *WebhookNotificationSink) DeliverNotification(notification *Notification) error {
+	var body bytes.Buffer
+	if err := sink.template.Execute(&body, notification); err != nil {
+		return fmt.Errorf("failed to render body template: %w", err)
+	}
+	req, err := http.NewRequest(sink.Method, sink.URL, &body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if sink.BodyTemplate == defaultWebhookBodyTemplate {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("User-Agent", "Notifier")
+	for name, value := range sink.Headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := sink.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook responded with status %v: %v", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+func init() {
+	registerSinkType("webhook", func(config sinkConfig, managers *sinkManagers) (NotificationSink, error) {
+		timeout, err := config.Duration("timeout")
+		if err != nil {
+			return nil, err
+		}
+		return &WebhookNotificationSink{
+			URL:          config.String("url"),
+			Method:       strings.ToUpper(config.String("method")),
+			Headers:      config.StringMap("headers"),
+			BodyTemplate: config.String("body"),
+			Timeout:      timeout,
+		}, nil
+	})
+}
@@ -0,0 +1,98 @@
+package notifier
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookNotificationSink(t *testing.T) {
+	notification := &Notification{Title: "Backup", Body: "finished", Timestamp: time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)}
+	tests := []struct {
+		name            string
+		sink            WebhookNotificationSink
+		status          int
+		wantMethod      string
+		wantBody        string
+		wantContentType string
+		wantErr         bool
+	}{
+		{
+			name:            "default body",
+			status:          http.StatusOK,
+			wantMethod:      http.MethodPost,
+			wantBody:        `{"timestamp":"2021-10-01T12:00:00Z","title":"Backup","body":"finished"}`,
+			wantContentType: "application/json",
+		},
+		{
+			name: "template",
+			sink: WebhookNotificationSink{
+				Method:       http.MethodPut,
+				Headers:      map[string]string{"Content-Type": "text/plain"},
+				BodyTemplate: `{{ .Title }}: {{ json .Body }}`,
+			},
+			status:          http.StatusNoContent,
+			wantMethod:      http.MethodPut,
+			wantBody:        `Backup: "finished"`,
+			wantContentType: "text/plain",
+		},
+		{
+			name:       "error status",
+			status:     http.StatusInternalServerError,
+			wantMethod: http.MethodPost,
+			wantBody:   `{"timestamp":"2021-10-01T12:00:00Z","title":"Backup","body":"finished"}`,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if r.Method != tt.wantMethod {
+					t.Errorf("method = %v, want %v", r.Method, tt.wantMethod)
+				}
+				if string(body) != tt.wantBody {
+					t.Errorf("body = %s, want %s", body, tt.wantBody)
+				}
+				if tt.wantContentType != "" && r.Header.Get("Content-Type") != tt.wantContentType {
+					t.Errorf("content type = %v, want %v", r.Header.Get("Content-Type"), tt.wantContentType)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+			sink := tt.sink
+			sink.URL = server.URL
+			if err := sink.Init(); err != nil {
+				t.Fatal(err)
+			}
+			err := sink.DeliverNotification(notification)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DeliverNotification() = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhookDefaultBodyIsTheNotification(t *testing.T) {
+	// the default body must stay decodable as a Notification for existing receivers
+	var received Notification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+	sink := &WebhookNotificationSink{URL: server.URL}
+	if err := sink.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.DeliverNotification(&Notification{Title: "Disk", Body: "full", Severity: Severity_Critical}); err != nil {
+		t.Fatal(err)
+	}
+	if received.Title != "Disk" || received.Body != "full" || received.Severity != Severity_Critical {
+		t.Errorf("received %+v", received)
+	}
+}
@@ -0,0 +1,406 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	webpush "github.com/SherClockHolmes/webpush-go"
+)
+
+// browsers show at most two action buttons in a notification
+const webPushMaxActionCount = 2
+
+// webPushMaxPayloadSize is the 4096 bytes of a push message minus the
+// encryption header, the authentication tag and the padding delimiter.
+const webPushMaxPayloadSize = 4096 - 86 - 16 - 1
+
+// WebPushNotificationSink sends encrypted Web Push messages to the browsers
+// subscribed through the /webpush page served by HttpServer. Questions are
+// shown with action buttons, which answer them through answer links.
+type WebPushNotificationSink struct {
+	VAPIDPublicKey    string
+	VAPIDPrivateKey   string
+	Subject           string // contact email of the sender, sent to the push services
+	SubscriptionsPath string
+	TTL               time.Duration
+	AllowedUsers      []string // usernames allowed to subscribe, every user when empty
+	AnswerLinks       *AnswerLinkManager
+	client            *http.Client
+
+	mutex         sync.Mutex
+	subscriptions []*WebPushSubscription
+}
+
+// WebPushSubscription is a PushSubscription registered by a browser.
+type WebPushSubscription struct {
+	webpush.Subscription
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type webPushAction struct {
+	Action string `json:"action"`
+	Title  string `json:"title"`
+}
+
+// webPushMessage is the payload shown by the service worker.
+type webPushMessage struct {
+	Title   string            `json:"title"`
+	Body    string            `json:"body"`
+	Tag     string            `json:"tag,omitempty"`
+	Actions []*webPushAction  `json:"actions,omitempty"`
+	Links   map[string]string `json:"links,omitempty"` // action -> answer link
+}
+
+func (sink *WebPushNotificationSink) Init() error {
+	if sink.VAPIDPublicKey == "" || sink.VAPIDPrivateKey == "" {
+		privateKey, publicKey, err := webpush.GenerateVAPIDKeys()
+		if err != nil {
+			return fmt.Errorf("vapid_public_key and vapid_private_key must be set")
+		}
+		return fmt.Errorf("vapid_public_key and vapid_private_key must be set, for example to the newly generated vapid_public_key: %v vapid_private_key: %v", publicKey, privateKey)
+	}
+	if sink.Subject == "" {
+		return fmt.Errorf("subject must be set to a contact email")
+	}
+	sink.Subject = strings.TrimPrefix(sink.Subject, "mailto:")
+	if sink.SubscriptionsPath == "" {
+		sink.SubscriptionsPath = "webpush_subscriptions.json"
+	}
+	if sink.TTL == 0 {
+		sink.TTL = 24 * time.Hour
+	}
+	sink.client = &http.Client{
+		Timeout: 30 * time.Second,
+	}
+	if err := sink.loadSubscriptions(); err != nil {
+		return err
+	}
+	log.Printf("Successfully initialized %T", sink)
+	return nil
+}
+
+func (sink *WebPushNotificationSink) DeliverNotification(notification *Notification) error {
+	title := notification.Title
+	if title == "" {
+		title = "Notification"
+	}
+	msg := &webPushMessage{
+		Title: title,
+		Body:  truncateText(notification.Body, 1000),
+		Tag:   notification.DedupKey,
+	}
+	return sink.push(webPushUrgency(notification.Severity), func(subscription *WebPushSubscription) (*webPushMessage, error) {
+		return msg, nil
+	})
+}
+
+func (sink *WebPushNotificationSink) AskQuestion(ctx context.Context, question *Question) (*Answer, error) {
+	if sink.AnswerLinks == nil {
+		return nil, fmt.Errorf("answer links are not available")
+	}
+	labels := []string{}
+	values := []interface{}{}
+	switch question.Kind {
+	case QuestionKind_YesNo:
+		labels = append(labels, "Yes", "No")
+		values = append(values, true, false)
+	case QuestionKind_Choice:
+		if len(question.Options) > webPushMaxActionCount {
+			return nil, fmt.Errorf("web push supports at most %v options", webPushMaxActionCount)
+		}
+		for _, option := range question.Options {
+			labels = append(labels, option.Label)
+			values = append(values, option.Value)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported question kind: %v", question.Kind)
+	}
+	expires, ok := ctx.Deadline()
+	if !ok {
+		expires = time.Now().Add(time.Hour * 100000)
+	}
+	questionAskedTime := time.Now()
+	answerChan := make(chan *Answer, 1)
+	listenerID, removeListener := sink.AnswerLinks.AddListener(question, func(answer *Answer) {
+		select {
+		case answerChan <- answer:
+		default:
+		}
+	})
+	defer removeListener()
+
+	// every subscription gets its own links, so that the answer is attributed to its user
+	err := sink.push(webpush.UrgencyHigh, func(subscription *WebPushSubscription) (*webPushMessage, error) {
+		answerer := &Answerer{
+			Sink:     "webpush",
+			ID:       subscription.Username,
+			Username: subscription.Username,
+		}
+		msg := &webPushMessage{
+			Title: "Question",
+			Body:  truncateText(question.Text, 1000),
+			Tag:   question.ID,
+			Links: map[string]string{},
+		}
+		for i, label := range labels {
+			link, err := sink.AnswerLinks.CreateLink(listenerID, values[i], answerer, expires)
+			if err != nil {
+				return nil, err
+			}
+			action := fmt.Sprintf("answer-%v", i)
+			msg.Actions = append(msg.Actions, &webPushAction{Action: action, Title: label})
+			msg.Links[action] = link
+		}
+		return msg, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to send question: %w", err)
+	}
+
+	select {
+	case answer := <-answerChan:
+		return answer, nil
+	case <-ctx.Done():
+		return &Answer{
+			Value:          question.TimeoutValue(),
+			TimedOut:       true,
+			AnwserDuration: time.Since(questionAskedTime),
+		}, nil
+	}
+}
+
+// push sends the message returned by the message function to every
+// subscription. Subscriptions which the push service reports as gone are removed.
+func (sink *WebPushNotificationSink) push(urgency webpush.Urgency, message func(subscription *WebPushSubscription) (*webPushMessage, error)) error {
+	subscriptions := sink.Subscriptions()
+	if len(subscriptions) == 0 {
+		return fmt.Errorf("no browser is subscribed, enable notifications at /webpush")
+	}
+	errs := []string{}
+	for _, subscription := range subscriptions {
+		msg, err := message(subscription)
+		if err != nil {
+			return err
+		}
+		payload, err := webPushPayload(msg)
+		if err != nil {
+			return err
+		}
+		resp, err := webpush.SendNotification(payload, &subscription.Subscription, &webpush.Options{
+			HTTPClient:      sink.client,
+			Subscriber:      sink.Subject,
+			TTL:             int(sink.TTL.Seconds()),
+			Urgency:         urgency,
+			VAPIDPublicKey:  sink.VAPIDPublicKey,
+			VAPIDPrivateKey: sink.VAPIDPrivateKey,
+		})
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		switch {
+		case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+			log.Printf("Removing expired web push subscription of %v", subscription.Username)
+			if err := sink.removeSubscriptions(func(s *WebPushSubscription) bool { return s.Endpoint == subscription.Endpoint }); err != nil {
+				log.Printf("failed to remove web push subscription: %v", err)
+			}
+		case resp.StatusCode < 200 || resp.StatusCode > 299:
+			errs = append(errs, fmt.Sprintf("push service responded with status %v: %v", resp.StatusCode, strings.TrimSpace(string(respBody))))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to push to %v of %v subscriptions: %v", len(errs), len(subscriptions), strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// webPushPayload serializes the message, shortening its body until it fits
+// in a push message.
+func webPushPayload(msg *webPushMessage) ([]byte, error) {
+	shortened := *msg
+	for {
+		payload, err := json.Marshal(&shortened)
+		if err != nil {
+			return nil, err
+		}
+		if len(payload) <= webPushMaxPayloadSize {
+			return payload, nil
+		}
+		if shortened.Body == "" {
+			return nil, fmt.Errorf("the message is too large for web push (%v bytes)", len(payload))
+		}
+		cut := len(shortened.Body) - (len(payload) - webPushMaxPayloadSize) - 1
+		for cut > 0 && !utf8.RuneStart(shortened.Body[cut]) {
+			cut--
+		}
+		if cut < 0 {
+			cut = 0
+		}
+		shortened.Body = shortened.Body[:cut]
+	}
+}
+
+// webPushUrgency maps the severity to the urgency, which lets the devices
+// delay unimportant messages to save battery.
+func webPushUrgency(severity Severity) webpush.Urgency {
+	switch severity {
+	case Severity_Emergency, Severity_Alert, Severity_Critical:
+		return webpush.UrgencyHigh
+	case Severity_Info:
+		return webpush.UrgencyLow
+	case Severity_Debug:
+		return webpush.UrgencyVeryLow
+	}
+	return webpush.UrgencyNormal
+}
+
+// Subscriptions returns the registered subscriptions.
+func (sink *WebPushNotificationSink) Subscriptions() []*WebPushSubscription {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	return append([]*WebPushSubscription{}, sink.subscriptions...)
+}
+
+// AddSubscription registers the subscription of the user's browser, replacing
+// the previous registration of the same endpoint.
+func (sink *WebPushNotificationSink) AddSubscription(user *User, subscription *webpush.Subscription) error {
+	if !sink.isAllowedToSubscribe(user) {
+		return fmt.Errorf("you are not allowed to subscribe to notifications")
+	}
+	if !strings.HasPrefix(subscription.Endpoint, "https://") {
+		return fmt.Errorf("the endpoint must be an https URL")
+	}
+	if subscription.Keys.Auth == "" || subscription.Keys.P256dh == "" {
+		return fmt.Errorf("the subscription keys are missing")
+	}
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	subscriptions := []*WebPushSubscription{}
+	for _, s := range sink.subscriptions {
+		if s.Endpoint != subscription.Endpoint {
+			subscriptions = append(subscriptions, s)
+		}
+	}
+	sink.subscriptions = append(subscriptions, &WebPushSubscription{
+		Subscription: *subscription,
+		Username:     user.Username,
+		CreatedAt:    time.Now(),
+	})
+	return sink.saveSubscriptions()
+}
+
+// RemoveSubscription removes the subscription of the endpoint, if it belongs to the user.
+func (sink *WebPushNotificationSink) RemoveSubscription(user *User, endpoint string) error {
+	if user == nil {
+		return nil
+	}
+	return sink.removeSubscriptions(func(s *WebPushSubscription) bool {
+		return s.Endpoint == endpoint && s.Username == user.Username
+	})
+}
+
+func (sink *WebPushNotificationSink) removeSubscriptions(match func(s *WebPushSubscription) bool) error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	subscriptions := []*WebPushSubscription{}
+	for _, s := range sink.subscriptions {
+		if !match(s) {
+			subscriptions = append(subscriptions, s)
+		}
+	}
+	if len(subscriptions) == len(sink.subscriptions) {
+		return nil
+	}
+	sink.subscriptions = subscriptions
+	return sink.saveSubscriptions()
+}
+
+func (sink *WebPushNotificationSink) isAllowedToSubscribe(user *User) bool {
+	if user == nil || user.Username == "" {
+		return false
+	}
+	if len(sink.AllowedUsers) == 0 {
+		return true
+	}
+	for _, allowed := range sink.AllowedUsers {
+		if allowed == user.Username {
+			return true
+		}
+	}
+	return false
+}
+
+// loadSubscriptions reads the subscriptions saved by a previous run. A missing file is not an error.
+func (sink *WebPushNotificationSink) loadSubscriptions() error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	data, err := os.ReadFile(sink.SubscriptionsPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read web push subscriptions %v: %w", sink.SubscriptionsPath, err)
+	}
+	if err := json.Unmarshal(data, &sink.subscriptions); err != nil {
+		return fmt.Errorf("failed to parse web push subscriptions %v: %w", sink.SubscriptionsPath, err)
+	}
+	return nil
+}
+
+// saveSubscriptions writes the subscriptions like QuestionStore.save. Must be
+// called with the mutex held.
+func (sink *WebPushNotificationSink) saveSubscriptions() error {
+	data, err := json.MarshalIndent(sink.subscriptions, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(sink.SubscriptionsPath), filepath.Base(sink.SubscriptionsPath)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to write web push subscriptions: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write web push subscriptions: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write web push subscriptions: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), sink.SubscriptionsPath); err != nil {
+		return fmt.Errorf("failed to write web push subscriptions: %w", err)
+	}
+	return nil
+}
+
+func init() {
+	registerSinkType("webpush", func(config sinkConfig, managers *sinkManagers) (NotificationSink, error) {
+		ttl, err := config.Duration("ttl")
+		if err != nil {
+			return nil, err
+		}
+		return &WebPushNotificationSink{
+			VAPIDPublicKey:    config.String("vapid_public_key"),
+			VAPIDPrivateKey:   config.String("vapid_private_key"),
+			Subject:           config.String("subject"),
+			SubscriptionsPath: config.String("subscriptions_path"),
+			TTL:               ttl,
+			AllowedUsers:      config.Strings("allowed_users"),
+			AnswerLinks:       managers.AnswerLinks,
+		}, nil
+	})
+}
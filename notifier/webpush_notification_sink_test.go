@@ -0,0 +1,317 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	webpush "github.com/SherClockHolmes/webpush-go"
+)
+
+// testPushSubscriber is the browser side of a subscription, which holds the
+// keys needed to decrypt the pushed messages.
+type testPushSubscriber struct {
+	privateKey []byte
+	publicKey  []byte
+	auth       []byte
+}
+
+func newTestPushSubscriber(t *testing.T) *testPushSubscriber {
+	privateKey, x, y, err := elliptic.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := make([]byte, 16)
+	if _, err := rand.Read(auth); err != nil {
+		t.Fatal(err)
+	}
+	return &testPushSubscriber{privateKey: privateKey, publicKey: elliptic.Marshal(elliptic.P256(), x, y), auth: auth}
+}
+
+func (s *testPushSubscriber) subscription(endpoint string) *webpush.Subscription {
+	return &webpush.Subscription{
+		Endpoint: endpoint,
+		Keys: webpush.Keys{
+			P256dh: base64.RawURLEncoding.EncodeToString(s.publicKey),
+			Auth:   base64.RawURLEncoding.EncodeToString(s.auth),
+		},
+	}
+}
+
+// hkdfSHA256 derives a key of at most 32 bytes as described in RFC 5869.
+func hkdfSHA256(secret []byte, salt []byte, info []byte, length int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write(info)
+	expand.Write([]byte{1})
+	return expand.Sum(nil)[:length]
+}
+
+// decrypt decrypts an aes128gcm encoded push message as described in RFC 8291.
+func (s *testPushSubscriber) decrypt(body []byte) ([]byte, error) {
+	if len(body) < 21 || len(body) < 21+int(body[20]) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	salt := body[:16]
+	serverPublicKey := body[21 : 21+int(body[20])]
+	ciphertext := body[21+int(body[20]):]
+
+	// the secret is computed like webpush-go does, which drops its leading zeros
+	x, y := elliptic.Unmarshal(elliptic.P256(), serverPublicKey)
+	sharedX, _ := elliptic.P256().ScalarMult(x, y, s.privateKey)
+	info := append(append([]byte("WebPush: info\x00"), s.publicKey...), serverPublicKey...)
+	ikm := hkdfSHA256(sharedX.Bytes(), s.auth, info, 32)
+	key := hkdfSHA256(ikm, salt, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := hkdfSHA256(ikm, salt, []byte("Content-Encoding: nonce\x00"), 12)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, err
+	}
+	// the last record ends with the delimiter 2 followed by the padding
+	plaintext = bytes.TrimRight(plaintext, "\x00")
+	return bytes.TrimSuffix(plaintext, []byte{2}), nil
+}
+
+type testPush struct {
+	endpoint string
+	header   http.Header
+	message  *webPushMessage
+}
+
+// newTestPushService returns a push service delivering the messages pushed to
+// the endpoints of the subscribers, which responds with 410 Gone to other endpoints.
+func newTestPushService(t *testing.T, subscribers map[string]*testPushSubscriber) (*httptest.Server, <-chan *testPush) {
+	pushes := make(chan *testPush, 16)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subscriber, ok := subscribers[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		payload, err := subscriber.decrypt(body)
+		if err != nil {
+			t.Errorf("failed to decrypt the message pushed to %v: %v", r.URL.Path, err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var msg webPushMessage
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			t.Errorf("failed to decode the message pushed to %v: %v", r.URL.Path, err)
+		}
+		pushes <- &testPush{endpoint: r.URL.Path, header: r.Header, message: &msg}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(server.Close)
+	return server, pushes
+}
+
+func newTestWebPushSink(t *testing.T, server *httptest.Server) *WebPushNotificationSink {
+	t.Helper()
+	privateKey, publicKey, err := webpush.GenerateVAPIDKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &WebPushNotificationSink{
+		VAPIDPublicKey:    publicKey,
+		VAPIDPrivateKey:   privateKey,
+		Subject:           "mailto:admin@example.com",
+		SubscriptionsPath: filepath.Join(t.TempDir(), "subscriptions.json"),
+	}
+	if err := sink.Init(); err != nil {
+		t.Fatal(err)
+	}
+	sink.client = server.Client()
+	return sink
+}
+
+func TestWebPushNotificationSinkDeliverNotification(t *testing.T) {
+	alice := newTestPushSubscriber(t)
+	server, pushes := newTestPushService(t, map[string]*testPushSubscriber{"/alice": alice})
+	sink := newTestWebPushSink(t, server)
+	if err := sink.DeliverNotification(&Notification{Body: "finished"}); err == nil {
+		t.Error("DeliverNotification() succeeded without subscriptions")
+	}
+	if err := sink.AddSubscription(&User{Username: "alice"}, alice.subscription(server.URL+"/alice")); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.AddSubscription(&User{Username: "bob"}, newTestPushSubscriber(t).subscription(server.URL+"/bob")); err != nil {
+		t.Fatal(err)
+	}
+
+	err := sink.DeliverNotification(&Notification{Title: "Disk", Body: "full", Severity: Severity_Critical, DedupKey: "disk"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	push := <-pushes
+	if push.message.Title != "Disk" || push.message.Body != "full" || push.message.Tag != "disk" {
+		t.Errorf("message = %+v", push.message)
+	}
+	if push.header.Get("Urgency") != "high" || push.header.Get("TTL") != "86400" || !strings.HasPrefix(push.header.Get("Authorization"), "vapid t=") {
+		t.Errorf("headers = %v", push.header)
+	}
+	// the push service does not know the endpoint of bob anymore
+	if subscriptions := sink.Subscriptions(); len(subscriptions) != 1 || subscriptions[0].Username != "alice" {
+		t.Errorf("subscriptions = %+v, want only the one of alice", subscriptions)
+	}
+
+	reloaded := &WebPushNotificationSink{
+		VAPIDPublicKey:    sink.VAPIDPublicKey,
+		VAPIDPrivateKey:   sink.VAPIDPrivateKey,
+		Subject:           sink.Subject,
+		SubscriptionsPath: sink.SubscriptionsPath,
+	}
+	if err := reloaded.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if subscriptions := reloaded.Subscriptions(); len(subscriptions) != 1 || subscriptions[0].Endpoint != server.URL+"/alice" {
+		t.Errorf("loaded subscriptions = %+v", subscriptions)
+	}
+}
+
+func TestWebPushNotificationSinkAskQuestion(t *testing.T) {
+	alice, bob := newTestPushSubscriber(t), newTestPushSubscriber(t)
+	server, pushes := newTestPushService(t, map[string]*testPushSubscriber{"/alice": alice, "/bob": bob})
+	sink := newTestWebPushSink(t, server)
+	answerLinks := newTestAnswerLinks(t)
+	sink.AnswerLinks = answerLinks
+	for username, subscriber := range map[string]*testPushSubscriber{"alice": alice, "bob": bob} {
+		if err := sink.AddSubscription(&User{Username: username}, subscriber.subscription(server.URL+"/"+username)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	answers := make(chan *Answer, 1)
+	go func() {
+		answer, err := sink.AskQuestion(ctx, &Question{ID: "q1", Kind: QuestionKind_YesNo, Text: "Deploy?"})
+		if err != nil {
+			t.Error(err)
+		}
+		answers <- answer
+	}()
+	links := map[string]map[string]string{}
+	for i := 0; i < 2; i++ {
+		push := <-pushes
+		if push.message.Body != "Deploy?" || push.message.Tag != "q1" || len(push.message.Actions) != 2 || push.message.Actions[1].Title != "No" {
+			t.Fatalf("message = %+v", push.message)
+		}
+		links[push.endpoint] = push.message.Links
+	}
+	if links["/alice"]["answer-1"] == links["/bob"]["answer-1"] {
+		t.Fatal("the subscriptions got the same answer links")
+	}
+	if _, err := answerLinks.Resolve(answerLinkToken(t, links["/bob"]["answer-1"]), ""); err != nil {
+		t.Fatal(err)
+	}
+	if answer := <-answers; answer.Value != false || answer.AnsweredBy.Username != "bob" || answer.AnsweredBy.Sink != "webpush" {
+		t.Errorf("answer = %+v by %+v, want no by bob", answer, answer.AnsweredBy)
+	}
+}
+
+func TestWebPushNotificationSinkSubscriptions(t *testing.T) {
+	server, _ := newTestPushService(t, nil)
+	sink := newTestWebPushSink(t, server)
+	sink.AllowedUsers = []string{"alice", "bob"}
+	subscriber := newTestPushSubscriber(t)
+	tests := []struct {
+		name         string
+		user         *User
+		subscription *webpush.Subscription
+		wantErr      bool
+	}{
+		{"allowed", &User{Username: "alice"}, subscriber.subscription("https://push.example.com/alice"), false},
+		{"not allowed", &User{Username: "mallory"}, subscriber.subscription("https://push.example.com/mallory"), true},
+		{"anonymous", nil, subscriber.subscription("https://push.example.com/anonymous"), true},
+		{"http endpoint", &User{Username: "alice"}, subscriber.subscription("http://push.example.com/alice"), true},
+		{"missing keys", &User{Username: "alice"}, &webpush.Subscription{Endpoint: "https://push.example.com/alice"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := sink.AddSubscription(tt.user, tt.subscription)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AddSubscription() = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+	if len(sink.Subscriptions()) != 1 {
+		t.Fatalf("got %v subscriptions, want 1", len(sink.Subscriptions()))
+	}
+
+	// only the user who subscribed can unsubscribe the endpoint
+	if err := sink.RemoveSubscription(&User{Username: "bob"}, "https://push.example.com/alice"); err != nil {
+		t.Fatal(err)
+	}
+	if len(sink.Subscriptions()) != 1 {
+		t.Error("another user removed the subscription")
+	}
+	if err := sink.RemoveSubscription(&User{Username: "alice"}, "https://push.example.com/alice"); err != nil {
+		t.Fatal(err)
+	}
+	if len(sink.Subscriptions()) != 0 {
+		t.Error("the subscription was not removed")
+	}
+}
+
+func TestWebPushPayload(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantBody  string
+		shortened bool
+	}{
+		{"short", "finished", "finished", false},
+		{"long", strings.Repeat("a", 5000), "", true},
+		{"multibyte", strings.Repeat("ż", 3000), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := webPushPayload(&webPushMessage{Title: "Backup", Body: tt.body})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(payload) > webPushMaxPayloadSize {
+				t.Errorf("payload has %v bytes, want at most %v", len(payload), webPushMaxPayloadSize)
+			}
+			var msg webPushMessage
+			if err := json.Unmarshal(payload, &msg); err != nil {
+				t.Fatal(err)
+			}
+			if !utf8.ValidString(msg.Body) || !strings.HasPrefix(tt.body, msg.Body) {
+				t.Errorf("body %q is not a prefix of the original body", msg.Body)
+			}
+			if !tt.shortened && msg.Body != tt.wantBody {
+				t.Errorf("body = %q, want %q", msg.Body, tt.wantBody)
+			}
+			if tt.shortened && len(payload) < webPushMaxPayloadSize-8 {
+				t.Errorf("payload has %v bytes, the body was shortened more than needed", len(payload))
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package notifier
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	xmpp "github.com/mattn/go-xmpp"
+)
+
+// XMPPCredentials identify a connection to an XMPP server. Sinks with the
+// same credentials share the connection.
+type XMPPCredentials struct {
+	JID               string
+	Password          string
+	Host              string // host:port, looked up from the SRV record of the JID domain when empty
+	DirectTLS         bool   // connects with TLS instead of upgrading the connection with STARTTLS
+	InsecurePlaintext bool   // authenticates over plain TCP when the server does not offer STARTTLS
+}
+
+type xmppMessageListener struct {
+	handler func(chat *xmpp.Chat)
+	ID      int64
+}
+
+// XMPPConnection is a connection to an XMPP server, which reconnects and
+// rejoins its multi-user chat rooms automatically.
+type XMPPConnection struct {
+	credentials XMPPCredentials
+	mutex       sync.RWMutex
+	client      *xmpp.Client
+	rooms       map[string]string // room JID -> nick
+	listeners   []*xmppMessageListener
+}
+
+// XMPPManager shares the XMPP connections between the sinks.
+type XMPPManager struct {
+	mutex       sync.Mutex
+	connections map[XMPPCredentials]*XMPPConnection
+}
+
+func NewXMPPManager() *XMPPManager {
+	return &XMPPManager{
+		connections: make(map[XMPPCredentials]*XMPPConnection),
+	}
+}
+
+func (m *XMPPManager) RegisterConnection(credentials XMPPCredentials) (*XMPPConnection, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if c, ok := m.connections[credentials]; ok {
+		return c, nil
+	}
+
+	c := &XMPPConnection{
+		credentials: credentials,
+		rooms:       make(map[string]string),
+	}
+	client, err := c.connect()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to xmpp as %v: %w", credentials.JID, err)
+	}
+	c.client = client
+	go c.receive()
+	go c.keepAlive()
+
+	m.connections[credentials] = c
+	return c, nil
+}
+
+func (c *XMPPConnection) connect() (*xmpp.Client, error) {
+	options := xmpp.Options{
+		Host:        c.credentials.Host,
+		User:        c.credentials.JID,
+		Password:    c.credentials.Password,
+		NoTLS:       !c.credentials.DirectTLS,
+		StartTLS:    !c.credentials.DirectTLS,
+		Resource:    "notifier",
+		DialTimeout: 30 * time.Second,
+		Session:     true,
+
+		InsecureAllowUnencryptedAuth: c.credentials.InsecurePlaintext,
+	}
+	client, err := options.NewClient()
+	if err != nil {
+		return nil, err
+	}
+	// go-xmpp carries on over plain TCP when the server does not offer STARTTLS
+	if !client.IsEncrypted() && !c.credentials.InsecurePlaintext {
+		client.Close()
+		return nil, fmt.Errorf("the server does not support STARTTLS, set insecure_plaintext to connect without TLS")
+	}
+	return client, nil
+}
+
+// receive reads the incoming stanzas and reconnects when the connection breaks.
+func (c *XMPPConnection) receive() {
+	backoff := time.Second
+	for {
+		c.mutex.RLock()
+		client := c.client
+		c.mutex.RUnlock()
+
+		stanza, err := client.Recv()
+		if err == nil {
+			backoff = time.Second
+			if chat, ok := stanza.(xmpp.Chat); ok {
+				c.dispatch(&chat)
+			}
+			continue
+		}
+
+		log.Printf("XMPP connection of %v lost: %v", c.credentials.JID, err)
+		client.Close()
+		for {
+			time.Sleep(backoff)
+			if backoff < time.Minute {
+				backoff *= 2
+			}
+			client, err = c.connect()
+			if err == nil {
+				break
+			}
+			log.Printf("Failed to reconnect to xmpp as %v: %v", c.credentials.JID, err)
+		}
+		c.mutex.Lock()
+		c.client = client
+		for room, nick := range c.rooms {
+			if _, err := client.JoinMUCNoHistory(room, nick); err != nil {
+				log.Printf("Failed to join xmpp room %v: %v", room, err)
+			}
+		}
+		c.mutex.Unlock()
+		log.Printf("Reconnected to xmpp as %v", c.credentials.JID)
+	}
+}
+
+func (c *XMPPConnection) keepAlive() {
+	for range time.Tick(time.Minute) {
+		c.mutex.RLock()
+		client := c.client
+		c.mutex.RUnlock()
+		client.SendKeepAlive()
+	}
+}
+
+// JoinRoom joins the multi-user chat room now and after every reconnection.
+func (c *XMPPConnection) JoinRoom(room string, nick string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.rooms[room]; ok {
+		return nil
+	}
+	if _, err := c.client.JoinMUCNoHistory(room, nick); err != nil {
+		return fmt.Errorf("failed to join room %v: %w", room, err)
+	}
+	c.rooms[room] = nick
+	return nil
+}
+
+// Send sends the text to a JID, or to a room when it was joined.
+func (c *XMPPConnection) Send(to string, text string) error {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	chatType := "chat"
+	if _, ok := c.rooms[to]; ok {
+		chatType = "groupchat"
+	}
+	_, err := c.client.Send(xmpp.Chat{
+		Remote: to,
+		Type:   chatType,
+		Text:   text,
+	})
+	return err
+}
+
+// IsRoom reports whether the JID is a room joined by the connection.
+func (c *XMPPConnection) IsRoom(jid string) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	_, ok := c.rooms[jid]
+	return ok
+}
+
+func (c *XMPPConnection) AddMessageListener(handler func(chat *xmpp.Chat)) func() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	added := &xmppMessageListener{
+		handler: handler,
+		ID:      rand.Int63(),
+	}
+	c.listeners = append(c.listeners, added)
+	return func() {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		for i, l := range c.listeners {
+			if l.ID == added.ID {
+				c.listeners = append(c.listeners[:i], c.listeners[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (c *XMPPConnection) dispatch(chat *xmpp.Chat) {
+	if chat.Text == "" || !chat.Stamp.IsZero() {
+		// skips the chat states and the delayed messages
+		return
+	}
+	c.mutex.RLock()
+	if chat.Type == "groupchat" {
+		room, nick := splitJIDResource(chat.Remote)
+		if c.rooms[room] == nick {
+			// our own message echoed by the room
+			c.mutex.RUnlock()
+			return
+		}
+	}
+	listeners := append([]*xmppMessageListener{}, c.listeners...)
+	c.mutex.RUnlock()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic in xmpp message handler: %v", r)
+		}
+	}()
+	for _, l := range listeners {
+		l.handler(chat)
+	}
+}
+
+// splitJIDResource splits a full JID into the bare JID and the resource, which
+// is the nick of the sender in rooms.
+func splitJIDResource(jid string) (string, string) {
+	if i := strings.Index(jid, "/"); i >= 0 {
+		return jid[:i], jid[i+1:]
+	}
+	return jid, ""
+}
@@ -0,0 +1,217 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	xmpp "github.com/mattn/go-xmpp"
+)
+
+// XMPPNotificationSink sends notifications to JIDs and multi-user chat rooms.
+// The connection is shared with the other XMPP sinks using the same account.
+// Questions are answered by sending a message starting with the short code
+// included in the prompt, like "K7QX yes".
+type XMPPNotificationSink struct {
+	JID               string
+	Password          string
+	Host              string
+	DirectTLS         bool
+	InsecurePlaintext bool     // connects without TLS when the server does not offer STARTTLS
+	To                []string // JIDs the messages are sent to directly
+	Rooms             []string // JIDs of the rooms the messages are sent to
+	Nick              string   // nick in the rooms, the local part of the JID by default
+	AllowedUsers      []string // bare JIDs answering in direct messages, anyone in the conversation can answer when empty
+	XMPPManager       *XMPPManager
+	connection        *XMPPConnection
+}
+
+func (sink *XMPPNotificationSink) Init() error {
+	if sink.JID == "" || sink.Password == "" {
+		return fmt.Errorf("jid and password must be set")
+	}
+	if len(sink.To) == 0 && len(sink.Rooms) == 0 {
+		return fmt.Errorf("to or rooms must be set")
+	}
+	if len(sink.AllowedUsers) > 0 {
+		// room nicks can be taken by anyone and go-xmpp does not expose the real
+		// JIDs of the occupants, so only the senders of direct messages are known
+		if len(sink.Rooms) > 0 {
+			return fmt.Errorf("allowed_users can't be enforced in rooms, the answers must come from direct messages of the to JIDs")
+		}
+		for _, allowed := range sink.AllowedUsers {
+			if !strings.Contains(allowed, "@") {
+				return fmt.Errorf("allowed_users must be bare JIDs like user@example.com, got %q", allowed)
+			}
+		}
+	}
+	if sink.Nick == "" {
+		sink.Nick = strings.Split(sink.JID, "@")[0]
+	}
+	connection, err := sink.XMPPManager.RegisterConnection(XMPPCredentials{
+		JID:               sink.JID,
+		Password:          sink.Password,
+		Host:              sink.Host,
+		DirectTLS:         sink.DirectTLS,
+		InsecurePlaintext: sink.InsecurePlaintext,
+	})
+	if err != nil {
+		return err
+	}
+	sink.connection = connection
+	for _, room := range sink.Rooms {
+		if err := connection.JoinRoom(room, sink.Nick); err != nil {
+			return err
+		}
+	}
+	log.Printf("Successfully initialized %T", *sink)
+	return nil
+}
+
+func (sink *XMPPNotificationSink) DeliverNotification(notification *Notification) error {
+	message := notification.Body
+	if notification.Title != "" {
+		message = notification.Title + "\n\n" + message
+	}
+	return sink.send(message)
+}
+
+func (sink *XMPPNotificationSink) send(message string) error {
+	for _, to := range append(append([]string{}, sink.To...), sink.Rooms...) {
+		if err := sink.connection.Send(to, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sink *XMPPNotificationSink) AskQuestion(ctx context.Context, question *Question) (*Answer, error) {
+	code := generateShortCode()
+	prompt, err := codedQuestionPrompt(question, code)
+	if err != nil {
+		return nil, err
+	}
+
+	questionAskedTime := time.Now()
+	answerChan := make(chan *Answer, 1)
+	removeListener := sink.connection.AddMessageListener(func(chat *xmpp.Chat) {
+		from, user := splitJIDResource(chat.Remote)
+		if chat.Type == "groupchat" {
+			if !sink.isRoom(from) {
+				return
+			}
+		} else {
+			if !sink.isRecipient(from) {
+				return
+			}
+			user = from
+		}
+		answerText, ok := parseCodedAnswer(code, chat.Text)
+		if !ok {
+			return
+		}
+		if !sink.isAllowedToAnswer(chat.Type, user) {
+			sink.reply(from, fmt.Sprintf("%v: you are not allowed to answer this question", user))
+			return
+		}
+		value, label, ok := parseTextAnswer(question, answerText)
+		if !ok {
+			sink.reply(from, fmt.Sprintf("Unrecognized answer for [%v]", code))
+			return
+		}
+		select {
+		case answerChan <- &Answer{
+			Value:          value,
+			AnwserDuration: time.Since(questionAskedTime),
+			AnsweredBy: &Answerer{
+				Sink:        "xmpp",
+				ID:          chat.Remote,
+				Username:    user,
+				DisplayName: user,
+			},
+			AnsweredAt: time.Now(),
+		}:
+			sink.reply(from, fmt.Sprintf("[%v] Answered: %v (by %v)", code, label, user))
+		default:
+		}
+	})
+	defer removeListener()
+
+	if err := sink.send(prompt); err != nil {
+		return nil, fmt.Errorf("failed to send question: %w", err)
+	}
+
+	select {
+	case answer := <-answerChan:
+		return answer, nil
+	case <-ctx.Done():
+		if err := sink.send(fmt.Sprintf("[%v] %v", code, question.TimeoutLabel())); err != nil {
+			log.Printf("failed to send xmpp message: %v", err)
+		}
+		return &Answer{
+			Value:          question.TimeoutValue(),
+			TimedOut:       true,
+			AnwserDuration: time.Since(questionAskedTime),
+		}, nil
+	}
+}
+
+func (sink *XMPPNotificationSink) reply(to string, message string) {
+	if err := sink.connection.Send(to, message); err != nil {
+		log.Printf("failed to send xmpp message: %v", err)
+	}
+}
+
+func (sink *XMPPNotificationSink) isRoom(jid string) bool {
+	for _, room := range sink.Rooms {
+		if strings.EqualFold(room, jid) {
+			return true
+		}
+	}
+	return false
+}
+
+func (sink *XMPPNotificationSink) isRecipient(jid string) bool {
+	for _, to := range sink.To {
+		if strings.EqualFold(to, jid) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAllowedToAnswer checks the bare JID of the sender, which the server
+// authenticates for direct messages only.
+func (sink *XMPPNotificationSink) isAllowedToAnswer(chatType string, user string) bool {
+	if len(sink.AllowedUsers) == 0 {
+		return true
+	}
+	if chatType == "groupchat" {
+		return false
+	}
+	for _, allowed := range sink.AllowedUsers {
+		if strings.EqualFold(allowed, user) {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	registerSinkType("xmpp", func(config sinkConfig, managers *sinkManagers) (NotificationSink, error) {
+		return &XMPPNotificationSink{
+			JID:               config.String("jid"),
+			Password:          config.String("password"),
+			Host:              config.String("host"),
+			DirectTLS:         config.Bool("direct_tls"),
+			InsecurePlaintext: config.Bool("insecure_plaintext"),
+			To:                config.Strings("to"),
+			Rooms:             config.Strings("rooms"),
+			Nick:              config.String("nick"),
+			AllowedUsers:      config.Strings("allowed_users"),
+			XMPPManager:       managers.XMPP,
+		}, nil
+	})
+}